@@ -24,7 +24,12 @@ package aresample
 import (
 	"testing"
 	"bytes"
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"io"
+	"math"
+	"time"
 )
 
 func TestSpline(t *testing.T) {
@@ -229,38 +234,38 @@ func TestPcmS16leResample_Kernel(t *testing.T) {
 		t.Error("invalid channel", npcm)
 	}
 
-	ipcm,isr,osr := []int16{17,9,33,5},16000,32000
-	if yo,consumed,err := resample_channel(ipcm,isr,osr,0,0); len(yo) != 0 || consumed != 0 || err != nil {
+	ipcm,isr,osr := []float64{17,9,33,5},16000,32000
+	if yo,consumed,_,err := resample_channel(nil,ipcm,newPosition(isr,osr,0),0,&splineInterpolator{}); len(yo) != 0 || consumed != 0 || err != nil {
 		t.Error("invalid yo", consumed, len(yo), yo)
 	}
 
-	ipcm,isr,osr = []int16{17,9,33,5, 0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0},16000,32000
-	if yo,consumed,err := resample_channel(ipcm,isr,osr,0,0); len(yo) != 8 || consumed != 4 || err != nil {
+	ipcm,isr,osr = []float64{17,9,33,5, 0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0},16000,32000
+	if yo,consumed,_,err := resample_channel(nil,ipcm,newPosition(isr,osr,0),0,&splineInterpolator{}); len(yo) != 8 || consumed != 4 || err != nil {
 		t.Error("invalid yo", consumed, len(yo), yo)
 	} else if yo[0] != 17 || yo[2] != 9 || yo[4] != 33 || yo[6] != 5 {
 		t.Error("invalid yo", consumed, yo)
-	} else if yo[1] != 8 || yo[3] != 26 || yo[5] != 16 || yo[7] != 2 {
+	} else if int16(yo[1]) != 8 || int16(yo[3]) != 26 || int16(yo[5]) != 16 || int16(yo[7]) != 2 {
 		t.Error("invalid yo", consumed, yo)
 	}
-	if yo,consumed,err := resample_channel(ipcm,isr,osr,8,4); len(yo) != 8 || consumed != 4 || err != nil {
+	if yo,consumed,_,err := resample_channel(nil,ipcm,newPosition(isr,osr,8),4,&splineInterpolator{}); len(yo) != 8 || consumed != 4 || err != nil {
 		t.Error("invalid yo", consumed, len(yo), yo)
 	} else if yo[0] != 17 || yo[2] != 9 || yo[4] != 33 || yo[6] != 5 {
 		t.Error("invalid yo", consumed, yo)
-	} else if yo[1] != 8 || yo[3] != 26 || yo[5] != 16 || yo[7] != 2 {
+	} else if int16(yo[1]) != 8 || int16(yo[3]) != 26 || int16(yo[5]) != 16 || int16(yo[7]) != 2 {
 		t.Error("invalid yo", consumed, yo)
 	}
-	if yo,consumed,err := resample_channel(ipcm,isr,osr,16,8); len(yo) != 8 || consumed != 4 || err != nil {
+	if yo,consumed,_,err := resample_channel(nil,ipcm,newPosition(isr,osr,16),8,&splineInterpolator{}); len(yo) != 8 || consumed != 4 || err != nil {
 		t.Error("invalid yo", consumed, len(yo), yo)
 	} else if yo[0] != 17 || yo[2] != 9 || yo[4] != 33 || yo[6] != 5 {
 		t.Error("invalid yo", consumed, yo)
-	} else if yo[1] != 8 || yo[3] != 26 || yo[5] != 16 || yo[7] != 2 {
+	} else if int16(yo[1]) != 8 || int16(yo[3]) != 26 || int16(yo[5]) != 16 || int16(yo[7]) != 2 {
 		t.Error("invalid yo", consumed, yo)
 	}
 
-	if npcm := resample_merge([]int16{0x01},nil); len(npcm) != 2 {
+	if npcm := resample_merge([][]float64{{0x01}}); len(npcm) != 2 {
 		t.Error("invalid merged data", len(npcm))
 	}
-	if npcm := resample_merge([]int16{0x01},[]int16{0x02}); len(npcm) != 4 {
+	if npcm := resample_merge([][]float64{{0x01},{0x02}}); len(npcm) != 4 {
 		t.Error("invalid merged data", len(npcm))
 	}
 }
@@ -274,8 +279,8 @@ func TestPcmS16leMono2Stereo(t *testing.T) {
 		t.Error("invalid pcm, err is", err)
 	}
 
-	if err := PcmS16leMono2Stereo(make([]byte, 0), nil); err == nil {
-		t.Error("invalid pcm, err is", err)
+	if err := PcmS16leMono2Stereo(make([]byte, 0), nil); err != nil {
+		t.Error("empty pcm, err is", err)
 	}
 
 	b := []byte{0x01, 0x02}
@@ -291,6 +296,193 @@ func TestPcmS16leMono2Stereo(t *testing.T) {
 	}
 }
 
+func TestPcmS16leMono2StereoOptions(t *testing.T) {
+	b := []byte{0x00, 0x40}
+	if err := PcmS16leMono2StereoOptions(b, make([]byte, 4), &Mono2StereoOptions{Pan: 1.1}); err == nil {
+		t.Error("invalid pan, err is", err)
+	}
+	for _,opts := range []*Mono2StereoOptions{
+		{Pan: math.NaN()}, {Pan: math.Inf(1)}, {Pan: math.Inf(-1)},
+		{Gain: math.NaN()}, {Gain: math.Inf(1)}, {Gain: math.Inf(-1)},
+	} {
+		var pe *ParamError
+		if err := PcmS16leMono2StereoOptions(b, make([]byte, 4), opts); !errors.Is(err, ErrInvalidParameter) || !errors.As(err, &pe) {
+			t.Error("invalid options", *opts, err)
+		}
+	}
+	if err := PcmS16leMono2StereoOptions(b, make([]byte, 4), &Mono2StereoOptions{Law: PanLaw(100)}); err == nil {
+		t.Error("invalid law, err is", err)
+	}
+	if err := PcmS16leMono2StereoOptions(b, make([]byte, 3), &Mono2StereoOptions{}); err == nil {
+		t.Error("invalid npcm, err is", err)
+	}
+
+	// The default is the same as before, the gain 0.7071 at center, and nil opts is the default.
+	pcm := make([]byte, 2*65536)
+	for i:=0; i<65536; i++ {
+		pcm[2*i],pcm[2*i+1] = byte(i),byte(i>>8)
+	}
+	for _,opts := range []*Mono2StereoOptions{nil, &Mono2StereoOptions{}} {
+		npcm := make([]byte, 2*len(pcm))
+		if err := PcmS16leMono2StereoOptions(pcm, npcm, opts); err != nil {
+			t.Error("mono2stereo failed, err is", err)
+			continue
+		}
+		for i:=0; i<65536; i++ {
+			v := int16(float32(int16(uint16(i))) * 0.7071)
+			l := int16(npcm[4*i]) | (int16(npcm[4*i+1]) << 8)
+			r := int16(npcm[4*i+2]) | (int16(npcm[4*i+3]) << 8)
+			if l != v || r != v {
+				t.Error("invalid sample", i, v, l, r)
+				break
+			}
+		}
+	}
+
+	sample := func(opts *Mono2StereoOptions) (int16, int16) {
+		b0 := make([]byte, len(b) * 2)
+		if err := PcmS16leMono2StereoOptions(b, b0, opts); err != nil {
+			t.Error("resample failed, err is", err)
+		}
+		return int16(b0[0]) | (int16(b0[1]) << 8),int16(b0[2]) | (int16(b0[3]) << 8)
+	}
+	for _,c := range []struct{
+		opts *Mono2StereoOptions
+		l, r int16
+	} {
+		{&Mono2StereoOptions{Law: PanLaw3dB}, 11585, 11585},
+		{&Mono2StereoOptions{Law: PanLaw0dB}, 0x4000, 0x4000},
+		{&Mono2StereoOptions{Law: PanLaw4_5dB}, 9741, 9741},
+		{&Mono2StereoOptions{Law: PanLaw6dB}, 0x2000, 0x2000},
+		{&Mono2StereoOptions{Pan: -1}, 0x4000, 0},
+		{&Mono2StereoOptions{Pan: 1, Law: PanLaw6dB}, 0, 0x4000},
+		{&Mono2StereoOptions{Pan: 0.5, Law: PanLaw0dB}, 0x2000, 0x4000},
+		{&Mono2StereoOptions{Law: PanLaw0dB, Gain: 12}, 0x7fff, 0x7fff},
+	} {
+		if l,r := sample(c.opts); l != c.l || r != c.r {
+			t.Error("invalid sample", *c.opts, l, r)
+		}
+	}
+
+	// The default is the -3dB center.
+	b0 := make([]byte, 4)
+	if err := PcmS16leMono2Stereo(b, b0); err != nil {
+		t.Error("resample failed, err is", err)
+	} else if l,r := sample(&Mono2StereoOptions{}); int16(b0[0]) | (int16(b0[1]) << 8) != l || int16(b0[2]) | (int16(b0[3]) << 8) != r {
+		t.Error("invalid default", b0)
+	}
+}
+
+func TestPcmS16leStereo2Mono(t *testing.T) {
+	if err := PcmS16leStereo2Mono(make([]byte, 2), make([]byte, 1), Stereo2MonoAverage); err == nil {
+		t.Error("invalid pcm, err is", err)
+	}
+
+	if err := PcmS16leStereo2Mono(make([]byte, 4), make([]byte, 4), Stereo2MonoAverage); err == nil {
+		t.Error("invalid npcm, err is", err)
+	}
+
+	if err := PcmS16leStereo2Mono(make([]byte, 0), nil, Stereo2MonoAverage); err != nil {
+		t.Error("empty pcm, err is", err)
+	}
+
+	if err := PcmS16leStereo2Mono(make([]byte, 4), make([]byte, 2), Stereo2MonoMode(100)); err == nil {
+		t.Error("invalid mode, err is", err)
+	}
+
+	// L=0x1000, R=0x2000, then L=0x7fff, R=0x7fff.
+	b := []byte{0x00, 0x10, 0x00, 0x20, 0xff, 0x7f, 0xff, 0x7f}
+	sample := func(mode Stereo2MonoMode) (int16, int16) {
+		b0 := make([]byte, len(b) / 2)
+		if err := PcmS16leStereo2Mono(b, b0, mode); err != nil {
+			t.Error("downmix failed, err is", err)
+		}
+		return int16(b0[0]) | (int16(b0[1]) << 8),int16(b0[2]) | (int16(b0[3]) << 8)
+	}
+	if v0,v1 := sample(Stereo2MonoAverage); v0 != 0x1800 || v1 != 0x7fff {
+		t.Error("invalid average", v0, v1)
+	}
+	if v0,v1 := sample(Stereo2MonoSum); v0 != 8688 || v1 != 0x7fff {
+		t.Error("invalid sum", v0, v1)
+	}
+	if v0,_ := sample(Stereo2MonoLeft); v0 != 0x1000 {
+		t.Error("invalid left", v0)
+	}
+	if v0,_ := sample(Stereo2MonoRight); v0 != 0x2000 {
+		t.Error("invalid right", v0)
+	}
+
+	// The inverted-phase stereo cancels to silence by average.
+	pcm := sinePcmS16le(440, 44100, 441, 10000)
+	stereo := make([]byte, 2*len(pcm))
+	for i:=0; i<len(pcm); i+=2 {
+		v := int16(pcm[i]) | (int16(pcm[i+1]) << 8)
+		stereo[2*i],stereo[2*i+1] = byte(v),byte(v >> 8)
+		stereo[2*i+2],stereo[2*i+3] = byte(-v),byte(-v >> 8)
+	}
+	if c,err := PcmS16leStereoCorrelation(stereo); err != nil || c > -0.99 {
+		t.Error("invalid correlation", c, err)
+	}
+	if inverted,err := PcmS16leStereoInverted(stereo); err != nil || !inverted {
+		t.Error("invalid inverted", inverted, err)
+	}
+	if inverted,err := PcmS16leStereoInverted(b); err != nil || inverted {
+		t.Error("invalid inverted", inverted, err)
+	}
+
+	npcm := make([]byte, len(pcm))
+	if err := PcmS16leStereo2Mono(stereo, npcm, Stereo2MonoAverage); err != nil || rmsPcmS16le(npcm, 0) > 1 {
+		t.Error("invalid average", rmsPcmS16le(npcm, 0), err)
+	}
+	if err := PcmS16leStereo2Mono(stereo, npcm, Stereo2MonoPhaseAware); err != nil || bytes.Compare(npcm, pcm) != 0 {
+		t.Error("invalid phase aware", err)
+	}
+
+	if _,err := NewStereo2Mono(Stereo2MonoMode(100)); !errors.Is(err, ErrInvalidParameter) {
+		t.Error("invalid mode", err)
+	}
+	d,_ := NewStereo2Mono(Stereo2MonoPhaseAware)
+	if err := d.DownmixPcmS16le(stereo, npcm); err != nil || !d.Inverted() || bytes.Compare(npcm, pcm) != 0 {
+		t.Error("invalid phase aware", d.Inverted(), err)
+	}
+	if d.Reset(); d.Inverted() {
+		t.Error("invalid reset")
+	}
+	if err := d.DownmixPcmS16le(stereo, make([]byte, 4)); !errors.Is(err, ErrBufferSize) {
+		t.Error("invalid npcm", err)
+	}
+
+	// The correlation of short chunks is about -0.5, where R is -L/2 and uncorrelated tone,
+	// the phase aware of each chunk flips, but the stateful downmixer holds it.
+	stereo = make([]byte, 4*64*690)
+	for i:=0; i<len(stereo); i+=4 {
+		vl := 10000 * math.Sin(2*math.Pi*440*float64(i/4)/44100)
+		vr := -vl/2 + 8660 * math.Sin(2*math.Pi*1234*float64(i/4)/44100)
+		binary.LittleEndian.PutUint16(stereo[i:], uint16(int16(vl)))
+		binary.LittleEndian.PutUint16(stereo[i+2:], uint16(int16(vr)))
+	}
+	var flips,holds int
+	var inverted,held bool
+	for i:=0; i<len(stereo); i+=4*64 {
+		chunk := stereo[i:i+4*64]
+		if v,_ := PcmS16leStereoInverted(chunk); i > 0 && v != inverted {
+			flips++
+		}
+		inverted,_ = PcmS16leStereoInverted(chunk)
+		if err := d.DownmixPcmS16le(chunk, make([]byte, len(chunk)/2)); err != nil {
+			t.Error("downmix failed, err is", err)
+			return
+		}
+		if i > 0 && d.Inverted() != held {
+			holds++
+		}
+		held = d.Inverted()
+	}
+	if flips < 10 || holds > 1 {
+		t.Error("invalid hysteresis", flips, holds, d.Inverted())
+	}
+}
+
 func TestPcmS16leResample_Basic(t *testing.T) {
 	if _,err := NewPcmS16leResampler(0, 0, 0); err == nil {
 		t.Error("invalid resampler")
@@ -317,13 +509,13 @@ func TestPcmS16leResample_Basic(t *testing.T) {
 		}
 	}
 	pfn0(nil, func(pcm,npcm []byte, err error){
-		if err == nil {
-			t.Error("invalid pcm, err is", err)
+		if err != nil || len(npcm) != 0 {
+			t.Error("empty pcm, err is", err)
 		}
 	})
 	pfn0([]byte{}, func(pcm,npcm []byte, err error){
-		if err == nil {
-			t.Error("invalid pcm, err is", err)
+		if err != nil || len(npcm) != 0 {
+			t.Error("empty pcm, err is", err)
 		}
 	})
 	pfn0([]byte{0x00,0x00,0x00,0x00,0x00,0x00,0x00}, func(pcm,npcm []byte, err error){
@@ -346,13 +538,13 @@ func TestPcmS16leResample_Basic(t *testing.T) {
 		}
 	}
 	pfn1(nil, func(pcm,npcm []byte, err error){
-		if err == nil {
-			t.Error("invalid pcm, err is", err)
+		if err != nil || len(npcm) != 0 {
+			t.Error("empty pcm, err is", err)
 		}
 	})
 	pfn1([]byte{}, func(pcm,npcm []byte, err error){
-		if err == nil {
-			t.Error("invalid pcm, err is", err)
+		if err != nil || len(npcm) != 0 {
+			t.Error("empty pcm, err is", err)
 		}
 	})
 	pfn1([]byte{0x00,0x00,0x00,0x00,0x00,0x00,0x00,0x00,0x00,0x00,0x00,0x00,0x00,0x00,}, func(pcm,npcm []byte, err error){
@@ -720,3 +912,2114 @@ func TestPcmS16leResample_Stereo(t *testing.T) {
 		}
 	})
 }
+
+// Generate the mono s16le pcm of sine wave.
+func sinePcmS16le(freq float64, sampleRate, nbSamples int, amplitude float64) []byte {
+	pcm := make([]byte, 2*nbSamples)
+	for i:=0; i<nbSamples; i++ {
+		v := int16(amplitude * math.Sin(2*math.Pi*freq*float64(i)/float64(sampleRate)))
+		pcm[2*i] = byte(v)
+		pcm[2*i+1] = byte(v >> 8)
+	}
+	return pcm
+}
+
+// The RMS of mono s16le pcm, skip the samples at start.
+func rmsPcmS16le(pcm []byte, skip int) float64 {
+	var sum float64
+	var n int
+	for i:=2*skip; i+1<len(pcm); i+=2 {
+		v := float64(int16(pcm[i]) | (int16(pcm[i+1]) << 8))
+		sum += v*v
+		n++
+	}
+	if n == 0 {
+		return 0
+	}
+	return math.Sqrt(sum/float64(n))
+}
+
+func TestPcmS16leResample_Sinc(t *testing.T) {
+	if _,err := NewPcmS16leSincResampler(1, 48000, 16000, 0, 0.9); err == nil {
+		t.Error("invalid taps")
+	}
+	if _,err := NewPcmS16leSincResampler(1, 48000, 16000, 31, 0.9); err == nil {
+		t.Error("invalid taps")
+	}
+	if _,err := NewPcmS16leSincResampler(1, 48000, 16000, 32, 0); err == nil {
+		t.Error("invalid cutoff")
+	}
+	if _,err := NewPcmS16leSincResampler(1, 48000, 16000, 32, 1.1); err == nil {
+		t.Error("invalid cutoff")
+	}
+	if _,err := NewPcmS16leSincResampler(65, 48000, 16000, 32, 0.9); err == nil {
+		t.Error("invalid channels")
+	}
+
+	pfn := func(freq float64, interp bool) float64 {
+		var r ResampleSampleRate
+		var err error
+		if interp {
+			r,err = NewPcmS16leSincResampler(1, 48000, 16000, 32, 0.9)
+		} else {
+			r,err = NewPcmS16leResampler(1, 48000, 16000)
+		}
+		if err != nil {
+			t.Error("invalid resampler, err is", err)
+			return 0
+		}
+
+		npcm,err := r.Resample(sinePcmS16le(freq, 48000, 4800, 10000))
+		if err != nil {
+			t.Error("resample failed, err is", err)
+			return 0
+		}
+		return rmsPcmS16le(npcm, 64)
+	}
+
+	// The 1KHZ is in passband, should be kept.
+	if v := pfn(1000, true); v < 6800 || v > 7300 {
+		t.Error("invalid passband rms", v)
+	}
+	// The 12KHZ is above the Nyquist of 16KHZ, should be filtered.
+	if v := pfn(12000, true); v > 100 {
+		t.Error("invalid stopband rms", v)
+	}
+	if v,s := pfn(12000, false), pfn(12000, true); v <= s {
+		t.Error("spline should alias more than sinc", v, s)
+	}
+}
+
+func TestPcmS16leResample_SincFrames(t *testing.T) {
+	pcm := sinePcmS16le(440, 44100, 4400, 10000)
+
+	r0,err := NewPcmS16leSincResampler(1, 44100, 48000, 16, 0.95)
+	if err != nil {
+		t.Error("invalid resampler, err is", err)
+		return
+	}
+	npcm0,err := r0.Resample(pcm)
+	if err != nil {
+		t.Error("resample failed, err is", err)
+		return
+	}
+
+	r1,err := NewPcmS16leSincResampler(1, 44100, 48000, 16, 0.95)
+	if err != nil {
+		t.Error("invalid resampler, err is", err)
+		return
+	}
+	var npcm1 []byte
+	for i:=0; i<len(pcm); i+=2*100 {
+		b,err := r1.Resample(pcm[i:i+2*100])
+		if err != nil {
+			t.Error("resample failed, err is", err)
+			return
+		}
+		npcm1 = append(npcm1, b...)
+	}
+
+	if len(npcm0) != len(npcm1) {
+		t.Error("invalid frames", len(npcm0), len(npcm1))
+		return
+	}
+	for i:=0; i<len(npcm0); i+=2 {
+		v0 := int16(npcm0[i]) | (int16(npcm0[i+1]) << 8)
+		v1 := int16(npcm1[i]) | (int16(npcm1[i+1]) << 8)
+		if v0-v1 > 1 || v1-v0 > 1 {
+			t.Error("invalid sample at", i/2, v0, v1)
+			return
+		}
+	}
+}
+
+func TestPcmS16leResample_Polyphase(t *testing.T) {
+	if _,err := NewPcmS16lePolyphaseResampler(1, 44100, 44087, 32, 0.9); err == nil {
+		t.Error("invalid phases")
+	}
+	if _,err := NewPcmS16lePolyphaseResampler(1, 44100, 48000, 31, 0.9); err == nil {
+		t.Error("invalid taps")
+	}
+
+	if v,err := newPolyphaseBank(44100, 48000, 32, 0.9); err != nil {
+		t.Error("invalid bank, err is", err)
+	} else if v.up != 160 || v.down != 147 {
+		t.Error("invalid bank", v.up, v.down)
+	}
+	if v,err := newPolyphaseBank(8000, 16000, 32, 0.9); err != nil {
+		t.Error("invalid bank, err is", err)
+	} else if v.up != 2 || v.down != 1 {
+		t.Error("invalid bank", v.up, v.down)
+	}
+
+	pfn := func(freq float64) float64 {
+		r,err := NewPcmS16lePolyphaseResampler(1, 48000, 16000, 32, 0.9)
+		if err != nil {
+			t.Error("invalid resampler, err is", err)
+			return 0
+		}
+
+		npcm,err := r.Resample(sinePcmS16le(freq, 48000, 4800, 10000))
+		if err != nil {
+			t.Error("resample failed, err is", err)
+			return 0
+		}
+		return rmsPcmS16le(npcm, 64)
+	}
+	if v := pfn(1000); v < 6800 || v > 7300 {
+		t.Error("invalid passband rms", v)
+	}
+	if v := pfn(12000); v > 100 {
+		t.Error("invalid stopband rms", v)
+	}
+}
+
+func TestPcmS16leResample_PolyphaseFrames(t *testing.T) {
+	// The 300HZ is periodic in 147 samples of 44.1KHZ, and 160 samples of 48KHZ.
+	period := sinePcmS16le(300, 44100, 147, 10000)
+	var pcm []byte
+	for i:=0; i<300; i++ {
+		pcm = append(pcm, period...)
+	}
+
+	r0,err := NewPcmS16lePolyphaseResampler(1, 44100, 48000, 32, 0.95)
+	if err != nil {
+		t.Error("invalid resampler, err is", err)
+		return
+	}
+	npcm0,err := r0.Resample(pcm)
+	if err != nil {
+		t.Error("resample failed, err is", err)
+		return
+	}
+
+	r1,err := NewPcmS16lePolyphaseResampler(1, 44100, 48000, 32, 0.95)
+	if err != nil {
+		t.Error("invalid resampler, err is", err)
+		return
+	}
+	var npcm1 []byte
+	for i:=0; i<len(pcm); i+=2*441 {
+		b,err := r1.Resample(pcm[i:i+2*441])
+		if err != nil {
+			t.Error("resample failed, err is", err)
+			return
+		}
+		npcm1 = append(npcm1, b...)
+	}
+
+	// The chunked output must be bit-identical.
+	if bytes.Compare(npcm0, npcm1) != 0 {
+		t.Error("invalid frames", len(npcm0), len(npcm1))
+	}
+
+	// The output must be exactly periodic.
+	for i:=2*160; i+2*160<len(npcm0); i+=2 {
+		if npcm0[i] != npcm0[i+2*160] || npcm0[i+1] != npcm0[i+2*160+1] {
+			t.Error("invalid period at", i/2)
+			return
+		}
+	}
+}
+
+func TestPcmS16leResample_Position(t *testing.T) {
+	if p := newPosition(44100, 48000, 0); p.pos != 0 || p.frac != 0 || p.num != 147 || p.den != 160 {
+		t.Error("invalid position", p)
+	}
+	if p := newPosition(44100, 48000, 161); p.pos != 147 || p.frac != 147 {
+		t.Error("invalid position", p)
+	}
+
+	// After years of 48KHZ, the position is still exact.
+	written := uint64(48000) * 3600 * 24 * 365 * 10
+	p := newPosition(44100, 48000, written)
+	if p.pos != written/160*147 || p.frac != 0 {
+		t.Error("invalid position", p)
+	}
+	for i:=0; i<1000; i++ {
+		p.next()
+	}
+	if e := newPosition(44100, 48000, written+1000); p != e {
+		t.Error("invalid position", p, e)
+	}
+}
+
+func TestPcmS16leResample_SplineFrames(t *testing.T) {
+	pcm := sinePcmS16le(440, 44100, 4400, 10000)
+
+	r0,err := NewPcmS16leResampler(1, 44100, 22010)
+	if err != nil {
+		t.Error("invalid resampler, err is", err)
+		return
+	}
+	npcm0,err := r0.Resample(pcm)
+	if err != nil {
+		t.Error("resample failed, err is", err)
+		return
+	}
+
+	r1,err := NewPcmS16leResampler(1, 44100, 22010)
+	if err != nil {
+		t.Error("invalid resampler, err is", err)
+		return
+	}
+	var npcm1 []byte
+	for i:=0; i<len(pcm); i+=2*100 {
+		b,err := r1.Resample(pcm[i:i+2*100])
+		if err != nil {
+			t.Error("resample failed, err is", err)
+			return
+		}
+		npcm1 = append(npcm1, b...)
+	}
+
+	// The chunked output must be bit-identical.
+	if bytes.Compare(npcm0, npcm1) != 0 {
+		t.Error("invalid frames", len(npcm0), len(npcm1))
+	}
+}
+
+func TestPcmS16leResample_Flush(t *testing.T) {
+	pfn := func(r ResampleSampleRate, channels, nbSamples, chunk, isr, osr int) {
+		pcm := make([]byte, 2*channels*nbSamples)
+		for i:=0; i<len(pcm); i++ {
+			pcm[i] = byte(i*7)
+		}
+
+		var npcm []byte
+		for i:=0; i<len(pcm); i+=2*channels*chunk {
+			b,err := r.Resample(pcm[i:i+2*channels*chunk])
+			if err != nil {
+				t.Error("resample failed, err is", err)
+				return
+			}
+			npcm = append(npcm, b...)
+		}
+
+		b,err := r.Flush()
+		if err != nil {
+			t.Error("flush failed, err is", err)
+			return
+		}
+		npcm = append(npcm, b...)
+
+		// The output is ceil(nbSamples*osr/isr) samples.
+		if e := (nbSamples*osr + isr - 1)/isr; len(npcm) != 2*channels*e {
+			t.Error("invalid samples", isr, osr, len(npcm)/2/channels, e)
+		}
+
+		// Nothing to flush again.
+		if b,err := r.Flush(); err != nil || len(b) != 0 {
+			t.Error("invalid flush", len(b), err)
+		}
+	}
+
+	for _,sr := range [][]int{{16000,32000}, {44100,48000}, {48000,44100}, {48000,16000}, {44100,22010}, {8000,8000}} {
+		for _,channels := range []int{1, 2} {
+			if r,err := NewPcmS16leResampler(channels, sr[0], sr[1]); err != nil {
+				t.Error("invalid resampler, err is", err)
+			} else {
+				pfn(r, channels, 1000, 100, sr[0], sr[1])
+			}
+			if r,err := NewPcmS16leSincResampler(channels, sr[0], sr[1], 32, 0.9); err != nil {
+				t.Error("invalid resampler, err is", err)
+			} else {
+				pfn(r, channels, 1000, 50, sr[0], sr[1])
+			}
+			if r,err := NewPcmS16lePolyphaseResampler(channels, sr[0], sr[1], 16, 0.9); err != nil {
+				t.Error("invalid resampler, err is", err)
+			} else {
+				pfn(r, channels, 1000, 1000, sr[0], sr[1])
+			}
+		}
+	}
+}
+
+func TestPcmS16leResample_Reset(t *testing.T) {
+	pcm := sinePcmS16le(440, 44100, 1000, 10000)
+
+	r,err := NewPcmS16leSincResampler(1, 44100, 48000, 32, 0.9)
+	if err != nil {
+		t.Error("invalid resampler, err is", err)
+		return
+	}
+
+	npcm0,err := r.Resample(pcm)
+	if err != nil {
+		t.Error("resample failed, err is", err)
+		return
+	}
+
+	r.Reset()
+	npcm1,err := r.Resample(pcm)
+	if err != nil {
+		t.Error("resample failed, err is", err)
+		return
+	}
+	if bytes.Compare(npcm0, npcm1) != 0 {
+		t.Error("invalid reset", len(npcm0), len(npcm1))
+	}
+}
+
+func TestPcmS16leResample_Reconfigure(t *testing.T) {
+	r,err := NewPcmS16leSincResampler(1, 44100, 48000, 32, 0.9)
+	if err != nil {
+		t.Error("invalid resampler, err is", err)
+		return
+	}
+	if err = r.Reconfigure(65, 44100, 48000); err == nil {
+		t.Error("invalid channels")
+	}
+	if err = r.Reconfigure(1, 0, 48000); err == nil {
+		t.Error("invalid sampleRate")
+	}
+	if err = r.Reconfigure(1, 44100, 0); err == nil {
+		t.Error("invalid nSampleRate")
+	}
+
+	// The 100HZ tone which is continuous at the switch point.
+	tone := func(sampleRate, from, nbSamples int) []byte {
+		pcm := make([]byte, 2*nbSamples)
+		for i:=0; i<nbSamples; i++ {
+			v := int16(10000 * math.Sin(2*math.Pi*100*float64(from+i)/float64(sampleRate)))
+			pcm[2*i] = byte(v)
+			pcm[2*i+1] = byte(v >> 8)
+		}
+		return pcm
+	}
+
+	var npcm []byte
+	for _,b := range [][]byte{tone(44100, 0, 4410), tone(44100, 4410, 4410)} {
+		o,err := r.Resample(b)
+		if err != nil {
+			t.Error("resample failed, err is", err)
+			return
+		}
+		npcm = append(npcm, o...)
+	}
+
+	// Switch the input to 32KHZ at 0.2s.
+	if err = r.Reconfigure(1, 32000, 48000); err != nil {
+		t.Error("reconfigure failed, err is", err)
+		return
+	}
+	for _,b := range [][]byte{tone(32000, 6400, 3200), tone(32000, 9600, 3200)} {
+		o,err := r.Resample(b)
+		if err != nil {
+			t.Error("resample failed, err is", err)
+			return
+		}
+		npcm = append(npcm, o...)
+	}
+	o,err := r.Flush()
+	if err != nil {
+		t.Error("flush failed, err is", err)
+		return
+	}
+	npcm = append(npcm, o...)
+
+	// The output timeline is continuous, 0.4s in 48KHZ.
+	if nb := len(npcm)/2; nb < 19200-1 || nb > 19200+1 {
+		t.Error("invalid samples", nb)
+	}
+
+	// No click, the output is the 100HZ tone, except the tail of flush.
+	for i:=64; i<19200-64; i++ {
+		v := int16(npcm[2*i]) | (int16(npcm[2*i+1]) << 8)
+		e := 10000 * math.Sin(2*math.Pi*100*float64(i)/48000)
+		if d := math.Abs(float64(v)-e); d > 100 {
+			t.Error("invalid sample at", i, v, e)
+			return
+		}
+	}
+
+	// Switch the mono to stereo.
+	if err = r.Reconfigure(2, 32000, 48000); err != nil {
+		t.Error("reconfigure failed, err is", err)
+		return
+	}
+	if o,err = r.Resample(make([]byte, 2*2*320)); err != nil {
+		t.Error("resample failed, err is", err)
+	} else if (len(o)%4) != 0 {
+		t.Error("invalid stereo", len(o))
+	}
+}
+
+func TestPcmS16leResample_Into(t *testing.T) {
+	pcm := make([]byte, 2*2*4410)
+	copy(pcm, sinePcmS16le(440, 44100, 2*4410, 10000))
+
+	r0,err := NewPcmS16leSincResampler(2, 44100, 48000, 32, 0.9)
+	if err != nil {
+		t.Error("invalid resampler, err is", err)
+		return
+	}
+	npcm0,err := r0.Resample(pcm)
+	if err != nil {
+		t.Error("resample failed, err is", err)
+		return
+	}
+
+	// Resample to small dst, which consumes part of src.
+	r1,err := NewPcmS16leSincResampler(2, 44100, 48000, 32, 0.9)
+	if err != nil {
+		t.Error("invalid resampler, err is", err)
+		return
+	}
+	if _,_,err = r1.ResampleInto(make([]byte, 3), pcm); !errors.Is(err, ErrBufferSize) {
+		t.Error("invalid dst", err)
+	}
+
+	var npcm1 []byte
+	dst := make([]byte, 1000)
+	for src := pcm; len(src) > 0; {
+		n,consumed,err := r1.ResampleInto(dst, src)
+		if err != nil {
+			t.Error("resample failed, err is", err)
+			return
+		}
+		if consumed == 0 || consumed > len(src) || (consumed%4) != 0 {
+			t.Error("invalid consumed", consumed)
+			return
+		}
+		npcm1 = append(npcm1, dst[:n]...)
+		src = src[consumed:]
+	}
+	if bytes.Compare(npcm0, npcm1) != 0 {
+		t.Error("invalid output", len(npcm0), len(npcm1))
+	}
+
+	// The bypass copy the src.
+	if r,err := NewPcmS16leResampler(2, 44100, 44100); err != nil {
+		t.Error("invalid resampler, err is", err)
+	} else if r.OutputSize(16) != 16 {
+		t.Error("invalid output size", r.OutputSize(16))
+	} else if n,consumed,err := r.ResampleInto(make([]byte, 8), pcm[:16]); err != nil || n != 8 || consumed != 8 {
+		t.Error("invalid bypass", n, consumed, err)
+	} else if n,consumed,err = r.ResampleInto(make([]byte, 3), pcm[:16]); !errors.Is(err, ErrBufferSize) || n != 0 || consumed != 0 {
+		t.Error("invalid bypass", n, consumed, err)
+	} else if n,consumed,err = r.ResampleInto(make([]byte, 3), nil); err != nil || n != 0 || consumed != 0 {
+		t.Error("invalid bypass", n, consumed, err)
+	}
+}
+
+func TestPcmS16leResample_IntoSmallDst(t *testing.T) {
+	pcm := make([]byte, 2*2*44100)
+	copy(pcm, sinePcmS16le(440, 44100, 2*44100, 10000))
+
+	for _,c := range []struct{
+		cfg Config
+		size int
+	}{
+		{Config{Channels: 2, SampleRate: 44100, NSampleRate: 48000}, 40},
+		{Config{Channels: 2, SampleRate: 44100, NSampleRate: 48000, Quality: QualityVeryHigh}, 512},
+		{Config{Channels: 2, SampleRate: 48000, NSampleRate: 8000}, 4},
+	} {
+		r0,_ := NewResampler(c.cfg)
+		npcm0,err := r0.Resample(pcm)
+		if err != nil {
+			t.Error("resample failed, err is", err)
+			return
+		}
+
+		// Loop the small dst over the long input, which never stalls.
+		var npcm1 []byte
+		r1,_ := NewResampler(c.cfg)
+		dst := make([]byte, c.size)
+		for src := pcm; len(src) > 0; {
+			n,consumed,err := r1.ResampleInto(dst, src)
+			if err != nil {
+				t.Error("resample failed, size is", c.size, "err is", err)
+				return
+			}
+			if consumed == 0 || n > len(dst) {
+				t.Error("stall", c.size, n, consumed, len(src))
+				return
+			}
+			npcm1 = append(npcm1, dst[:n]...)
+			src = src[consumed:]
+		}
+		if bytes.Compare(npcm0, npcm1) != 0 {
+			t.Error("invalid output", c.size, len(npcm0), len(npcm1))
+		}
+	}
+}
+
+func TestPcmS16leResample_IntoAllocs(t *testing.T) {
+	pcm := make([]byte, 2*2*441)
+	copy(pcm, sinePcmS16le(440, 44100, 2*441, 10000))
+
+	for _,create := range []func() (ResampleSampleRate, error){
+		func() (ResampleSampleRate, error) {
+			return NewPcmS16leResampler(2, 44100, 48000)
+		},
+		func() (ResampleSampleRate, error) {
+			return NewPcmS16leSincResampler(2, 44100, 48000, 32, 0.9)
+		},
+		func() (ResampleSampleRate, error) {
+			return NewPcmS16lePolyphaseResampler(2, 44100, 48000, 32, 0.9)
+		},
+	} {
+		r,err := create()
+		if err != nil {
+			t.Error("invalid resampler, err is", err)
+			return
+		}
+
+		dst := make([]byte, 2*r.OutputSize(len(pcm)))
+		allocs := testing.AllocsPerRun(100, func() {
+			if _,consumed,err := r.ResampleInto(dst, pcm); err != nil || consumed != len(pcm) {
+				t.Error("resample failed", consumed, err)
+			}
+		})
+		if allocs != 0 {
+			t.Error("invalid allocs", allocs)
+		}
+	}
+}
+
+func TestPcmS16leResample_Float(t *testing.T) {
+	r,err := NewPcmS16leSincResampler(2, 44100, 48000, 32, 0.9)
+	if err != nil {
+		t.Error("invalid resampler, err is", err)
+		return
+	}
+	if _,err = r.ResampleFloat32(make([]float32, 9)); err == nil {
+		t.Error("invalid interleaved")
+	}
+	if npcm,err := r.ResampleFloat64(nil); err != nil || len(npcm) != 0 {
+		t.Error("empty interleaved", err)
+	}
+	if _,err = r.ResamplePlanarFloat32([][]float32{make([]float32, 8)}); err == nil {
+		t.Error("invalid planar")
+	}
+	if _,err = r.ResamplePlanarFloat64([][]float64{make([]float64, 8), make([]float64, 7)}); err == nil {
+		t.Error("invalid planar")
+	}
+
+	// The tiny signal, which is lost in int16.
+	nbSamples := 4410
+	left,right := make([]float32, nbSamples),make([]float32, nbSamples)
+	interleaved := make([]float32, 2*nbSamples)
+	for i:=0; i<nbSamples; i++ {
+		left[i] = float32(1e-6 * math.Sin(2*math.Pi*440*float64(i)/44100))
+		right[i] = -left[i]
+		interleaved[2*i],interleaved[2*i+1] = left[i],right[i]
+	}
+
+	r0,_ := NewPcmS16leSincResampler(2, 44100, 48000, 32, 0.9)
+	npcm0,err := r0.ResampleFloat32(interleaved)
+	if err != nil {
+		t.Error("resample failed, err is", err)
+		return
+	}
+	if tail,err := r0.FlushFloat32(); err != nil {
+		t.Error("flush failed, err is", err)
+	} else {
+		npcm0 = append(npcm0, tail...)
+	}
+	if len(npcm0) != 2*4800 {
+		t.Error("invalid samples", len(npcm0))
+	}
+
+	r1,_ := NewPcmS16leSincResampler(2, 44100, 48000, 32, 0.9)
+	npcm1,err := r1.ResamplePlanarFloat32([][]float32{left, right})
+	if err != nil {
+		t.Error("resample failed, err is", err)
+		return
+	}
+	if tail,err := r1.FlushPlanarFloat32(); err != nil {
+		t.Error("flush failed, err is", err)
+	} else {
+		npcm1[0],npcm1[1] = append(npcm1[0], tail[0]...),append(npcm1[1], tail[1]...)
+	}
+	if len(npcm1) != 2 || len(npcm1[0]) != 4800 || len(npcm1[1]) != 4800 {
+		t.Error("invalid planar samples")
+		return
+	}
+
+	var peak float64
+	for i:=0; i<4800; i++ {
+		if npcm0[2*i] != npcm1[0][i] || npcm0[2*i+1] != npcm1[1][i] {
+			t.Error("invalid planar at", i, npcm0[2*i], npcm1[0][i])
+			return
+		}
+		if npcm1[0][i] != -npcm1[1][i] {
+			t.Error("invalid stereo at", i, npcm1[0][i], npcm1[1][i])
+			return
+		}
+		peak = math.Max(peak, math.Abs(float64(npcm1[0][i])))
+	}
+	if peak < 0.9e-6 || peak > 1.1e-6 {
+		t.Error("invalid peak", peak)
+	}
+
+	// The float64 is the same to s16le, in the scale of int16.
+	pcm := sinePcmS16le(440, 44100, 1000, 10000)
+	f64 := make([]float64, 1000)
+	for i := range f64 {
+		f64[i] = float64(int16(pcm[2*i]) | (int16(pcm[2*i+1]) << 8)) / 32768
+	}
+	r2,_ := NewPcmS16leResampler(1, 44100, 22050)
+	r3,_ := NewPcmS16leResampler(1, 44100, 22050)
+	npcm2,err := r2.Resample(pcm)
+	if err != nil {
+		t.Error("resample failed, err is", err)
+		return
+	}
+	npcm3,err := r3.ResampleFloat64(f64)
+	if err != nil {
+		t.Error("resample failed, err is", err)
+		return
+	}
+	if len(npcm2) != 2*len(npcm3) {
+		t.Error("invalid samples", len(npcm2), len(npcm3))
+		return
+	}
+	for i := range npcm3 {
+		if v := int16(npcm2[2*i]) | (int16(npcm2[2*i+1]) << 8); v != int16(npcm3[i]*32768) {
+			t.Error("invalid sample at", i, v, npcm3[i]*32768)
+			return
+		}
+	}
+}
+
+func TestSampleFormat(t *testing.T) {
+	if err := (SampleFormat{Bits: 12, Signed: true}).Validate(); err == nil {
+		t.Error("invalid bits")
+	}
+	if err := (SampleFormat{Bits: 16, Float: true}).Validate(); err == nil {
+		t.Error("invalid float bits")
+	}
+
+	for _,f := range []struct{
+		format SampleFormat
+		name string
+		size int
+	}{
+		{FormatU8, "u8", 1}, {FormatS16LE, "s16le", 2}, {FormatS16BE, "s16be", 2},
+		{FormatS24LE, "s24le", 3}, {FormatS24In32BE, "s24in32be", 4}, {FormatS32LE, "s32le", 4},
+		{FormatF32BE, "f32be", 4}, {FormatF64LE, "f64le", 8},
+	} {
+		if f.format.String() != f.name || f.format.BytesPerSample() != f.size {
+			t.Error("invalid format", f.format, f.format.BytesPerSample())
+		}
+	}
+
+	pcm := []byte{0x00,0x00, 0x01,0x00, 0xff,0xff, 0xff,0x7f, 0x00,0x80, 0x34,0x12}
+	if _,err := ConvertSampleFormat(pcm[:3], FormatS16LE, FormatS16BE); err == nil {
+		t.Error("invalid pcm")
+	}
+	if _,err := ConvertSampleFormat(pcm, FormatS16LE, SampleFormat{}); err == nil {
+		t.Error("invalid format")
+	}
+
+	if npcm,err := ConvertSampleFormat(pcm, FormatS16LE, FormatS16BE); err != nil {
+		t.Error("convert failed, err is", err)
+	} else if bytes.Compare(npcm, []byte{0x00,0x00, 0x00,0x01, 0xff,0xff, 0x7f,0xff, 0x80,0x00, 0x12,0x34}) != 0 {
+		t.Error("invalid s16be", npcm)
+	}
+	if npcm,err := ConvertSampleFormat(pcm, FormatS16LE, FormatS24LE); err != nil {
+		t.Error("convert failed, err is", err)
+	} else if bytes.Compare(npcm[3:6], []byte{0x00,0x01,0x00}) != 0 || bytes.Compare(npcm[12:15], []byte{0x00,0x00,0x80}) != 0 {
+		t.Error("invalid s24le", npcm)
+	}
+	if npcm,err := ConvertSampleFormat(pcm, FormatS16LE, FormatU8); err != nil {
+		t.Error("convert failed, err is", err)
+	} else if bytes.Compare(npcm, []byte{0x80, 0x80, 0x80, 0xff, 0x00, 0x92}) != 0 {
+		t.Error("invalid u8", npcm)
+	}
+
+	// Convert back to s16le, which is lossless.
+	for _,f := range []SampleFormat{FormatS16BE, FormatU16LE, FormatS24LE, FormatS24BE, FormatS24In32LE,
+		FormatS24In32BE, FormatS32LE, FormatS32BE, FormatF32LE, FormatF32BE, FormatF64LE, FormatF64BE} {
+		if npcm,err := ConvertSampleFormat(pcm, FormatS16LE, f); err != nil {
+			t.Error("convert failed, err is", err)
+		} else if npcm,err = ConvertSampleFormat(npcm, f, FormatS16LE); err != nil {
+			t.Error("convert failed, err is", err)
+		} else if bytes.Compare(npcm, pcm) != 0 {
+			t.Error("invalid convert", f, npcm)
+		}
+	}
+
+	// Saturate the float out of range.
+	f32 := make([]byte, 8)
+	binary.LittleEndian.PutUint32(f32, math.Float32bits(1.5))
+	binary.LittleEndian.PutUint32(f32[4:], math.Float32bits(-1.5))
+	if npcm,err := ConvertSampleFormat(f32, FormatF32LE, FormatS16LE); err != nil {
+		t.Error("convert failed, err is", err)
+	} else if bytes.Compare(npcm, []byte{0xff,0x7f, 0x00,0x80}) != 0 {
+		t.Error("invalid saturate", npcm)
+	}
+}
+
+func TestPcmS16leResample_SampleFormat(t *testing.T) {
+	pcm := sinePcmS16le(440, 44100, 1000, 10000)
+
+	r0,_ := NewPcmS16leResampler(1, 44100, 48000)
+	npcm0,err := r0.Resample(pcm)
+	if err != nil {
+		t.Error("resample failed, err is", err)
+		return
+	}
+
+	// Resample s24le to s16be.
+	r1,_ := NewPcmS16leResampler(1, 44100, 48000)
+	if err = r1.SetSampleFormat(FormatS24LE, SampleFormat{Bits: 7}); err == nil {
+		t.Error("invalid format")
+	}
+	if err = r1.SetSampleFormat(FormatS24LE, FormatS16BE); err != nil {
+		t.Error("invalid format, err is", err)
+		return
+	}
+	if _,err = r1.Resample(pcm); err == nil {
+		t.Error("invalid s24le")
+	}
+	s24,_ := ConvertSampleFormat(pcm, FormatS16LE, FormatS24LE)
+	npcm1,err := r1.Resample(s24)
+	if err != nil {
+		t.Error("resample failed, err is", err)
+		return
+	}
+	if npcm1,err = ConvertSampleFormat(npcm1, FormatS16BE, FormatS16LE); err != nil {
+		t.Error("convert failed, err is", err)
+	} else if bytes.Compare(npcm0, npcm1) != 0 {
+		t.Error("invalid s16be", len(npcm0), len(npcm1))
+	}
+
+	// Bypass but convert the format.
+	r2,_ := NewPcmS16leResampler(1, 44100, 44100)
+	if err = r2.SetSampleFormat(FormatS16LE, FormatS24LE); err != nil {
+		t.Error("invalid format, err is", err)
+	} else if npcm,err := r2.Resample(pcm); err != nil || bytes.Compare(npcm, s24) != 0 {
+		t.Error("invalid bypass", err)
+	} else if r2.OutputSize(len(pcm)) != len(s24) {
+		t.Error("invalid output size", r2.OutputSize(len(pcm)))
+	}
+}
+
+func TestPcmS16leResample_MultiChannels(t *testing.T) {
+	// The 5.1 pcm, each channel is a different tone.
+	var mono [][]byte
+	for ch:=0; ch<6; ch++ {
+		mono = append(mono, sinePcmS16le(float64(100*(ch+1)), 48000, 4800, 8000))
+	}
+	pcm := make([]byte, 0, 6*2*4800)
+	for i:=0; i<4800; i++ {
+		for ch:=0; ch<6; ch++ {
+			pcm = append(pcm, mono[ch][2*i], mono[ch][2*i+1])
+		}
+	}
+
+	r,err := NewPcmS16leSincResampler(6, 48000, 44100, 32, 0.9)
+	if err != nil {
+		t.Error("invalid resampler, err is", err)
+		return
+	}
+	var npcm []byte
+	for i:=0; i<len(pcm); i+=6*2*480 {
+		o,err := r.Resample(pcm[i:i+6*2*480])
+		if err != nil {
+			t.Error("resample failed, err is", err)
+			return
+		}
+		npcm = append(npcm, o...)
+	}
+
+	// Each channel is same to the mono resampler.
+	for ch:=0; ch<6; ch++ {
+		m,err := NewPcmS16leSincResampler(1, 48000, 44100, 32, 0.9)
+		if err != nil {
+			t.Error("invalid resampler, err is", err)
+			return
+		}
+		o,err := m.Resample(mono[ch])
+		if err != nil {
+			t.Error("resample failed, err is", err)
+			return
+		}
+		if len(o)*6 != len(npcm) {
+			t.Error("invalid size", len(o)*6, len(npcm))
+			return
+		}
+		for i:=0; i<len(o)/2; i++ {
+			if o[2*i] != npcm[12*i+2*ch] || o[2*i+1] != npcm[12*i+2*ch+1] {
+				t.Error("invalid sample at", ch, i)
+				return
+			}
+		}
+	}
+
+	// Reconfigure 5.1 to stereo and mono.
+	if err = r.Reconfigure(2, 48000, 44100); err != nil {
+		t.Error("reconfigure failed, err is", err)
+		return
+	}
+	if npcm,err = r.Resample(pcm[:2*2*480]); err != nil || len(npcm)%4 != 0 {
+		t.Error("resample failed, err is", err, len(npcm))
+	}
+	if err = r.Reconfigure(1, 48000, 44100); err != nil {
+		t.Error("reconfigure failed, err is", err)
+		return
+	}
+	if npcm,err = r.Resample(mono[0][:2*480]); err != nil || len(npcm) == 0 {
+		t.Error("resample failed, err is", err, len(npcm))
+	}
+}
+
+func TestChannelLayout(t *testing.T) {
+	for _,c := range []struct{
+		layout   ChannelLayout
+		channels int
+		name     string
+	} {
+		{LayoutMono, 1, "mono"},
+		{LayoutStereo, 2, "stereo"},
+		{Layout2Point1, 3, "2.1"},
+		{LayoutQuad, 4, "quad"},
+		{Layout5Point1, 6, "5.1"},
+		{Layout7Point1, 8, "7.1"},
+	} {
+		if c.layout.Channels() != c.channels || c.layout.String() != c.name || LayoutOf(c.channels) != c.layout {
+			t.Error("invalid layout", c.layout, c.layout.Channels())
+		}
+	}
+
+	l := ChannelLayout(SpeakerFrontLeft | SpeakerFrontRight | SpeakerBackCenter)
+	if l.String() != "FL+FR+BC" || l.Index(SpeakerBackCenter) != 2 || l.Index(SpeakerFrontCenter) != -1 {
+		t.Error("invalid layout", l)
+	}
+	if Layout5Point1.Index(SpeakerLowFrequency) != 3 || Layout5Point1.Index(SpeakerBackRight) != 5 {
+		t.Error("invalid 5.1 index")
+	}
+	if ChannelLayout(0).Validate() == nil || LayoutOf(5) != 0 {
+		t.Error("invalid layout")
+	}
+}
+
+func TestRemixer(t *testing.T) {
+	near := func(a, b float64) bool {
+		return math.Abs(a-b) < 1e-6
+	}
+
+	// The 5.1 to stereo, L=FL+0.707*FC+0.707*BL, normalized.
+	m,err := RemixMatrix(Layout5Point1, LayoutStereo)
+	if err != nil {
+		t.Error("invalid matrix, err is", err)
+		return
+	}
+	n := 1 + 2*remixGain
+	if e := []float64{1/n, 0, remixGain/n, 0, remixGain/n, 0}; len(m) != 2 || !near(m[0][0], e[0]) || !near(m[0][2], e[2]) || !near(m[0][4], e[4]) || m[0][1] != 0 || m[0][3] != 0 || m[0][5] != 0 {
+		t.Error("invalid left", m[0])
+	}
+	if !near(m[1][1], 1/n) || !near(m[1][2], remixGain/n) || !near(m[1][5], remixGain/n) || m[1][3] != 0 {
+		t.Error("invalid right", m[1])
+	}
+
+	// The mono to stereo at -3dB, the stereo to mono by average.
+	if m,_ = RemixMatrix(LayoutMono, LayoutStereo); !near(m[0][0], remixGain) || !near(m[1][0], remixGain) {
+		t.Error("invalid mono to stereo", m)
+	}
+	if m,_ = RemixMatrix(LayoutStereo, LayoutMono); !near(m[0][0], 0.5) || !near(m[0][1], 0.5) {
+		t.Error("invalid stereo to mono", m)
+	}
+	// The 7.1 to 5.1, the side mixed to back.
+	if m,_ = RemixMatrix(Layout7Point1, Layout5Point1); !near(m[4][4], 0.5) || !near(m[4][6], 0.5) || !near(m[5][7], 0.5) {
+		t.Error("invalid 7.1 to 5.1", m)
+	}
+
+	r,err := NewRemixer(Layout5Point1, LayoutStereo)
+	if err != nil {
+		t.Error("invalid remixer, err is", err)
+		return
+	}
+	pcm := []byte{0x00,0x10, 0x00,0x20, 0x00,0x10, 0xff,0x7f, 0x00,0x00, 0x00,0x00}
+	npcm := make([]byte, 4)
+	if err = r.RemixPcmS16le(pcm, npcm); err != nil {
+		t.Error("remix failed, err is", err)
+		return
+	}
+	l := int16(npcm[0]) | (int16(npcm[1]) << 8)
+	rr := int16(npcm[2]) | (int16(npcm[3]) << 8)
+	if e := int16((0x1000 + remixGain*0x1000)/n); l != e {
+		t.Error("invalid left", l, e)
+	}
+	if e := int16((0x2000 + remixGain*0x1000)/n); rr != e {
+		t.Error("invalid right", rr, e)
+	}
+	if err = r.RemixPcmS16le(pcm, make([]byte, 3)); err == nil {
+		t.Error("invalid npcm")
+	}
+	if err = r.RemixPcmS16le(pcm[:10], npcm); err == nil {
+		t.Error("invalid pcm")
+	}
+
+	// The custom matrix, swap the left and right.
+	if _,err = NewRemixerMatrix(LayoutStereo, LayoutStereo, [][]float64{{0, 1}}); err == nil {
+		t.Error("invalid matrix")
+	}
+	if r,err = NewRemixerMatrix(LayoutStereo, LayoutStereo, [][]float64{{0, 1}, {1, 0}}); err != nil {
+		t.Error("invalid remixer, err is", err)
+		return
+	}
+	fpcm := make([]float64, 4)
+	if err = r.RemixFloat64([]float64{0.1, 0.2, 0.3, 0.4}, fpcm); err != nil || fpcm[0] != 0.2 || fpcm[1] != 0.1 || fpcm[3] != 0.3 {
+		t.Error("invalid remix", fpcm, err)
+	}
+}
+
+func TestPcmS16leResample_Saturate(t *testing.T) {
+	if v,c := resample_saturate(32767.9); v != 32767 || c {
+		t.Error("invalid saturate", v, c)
+	}
+	if v,c := resample_saturate(32768); v != 32767 || !c {
+		t.Error("invalid saturate", v, c)
+	}
+	if v,c := resample_saturate(-32768.9); v != -32768 || c {
+		t.Error("invalid saturate", v, c)
+	}
+	if v,c := resample_saturate(-40000); v != -32768 || !c {
+		t.Error("invalid saturate", v, c)
+	}
+
+	// The full-scale square wave, the spline overshoots near the edges.
+	pcm := make([]byte, 2*1000)
+	for i:=0; i<1000; i++ {
+		v := int16(0x7fff)
+		if (i/10)%2 == 1 {
+			v = -0x8000
+		}
+		pcm[2*i],pcm[2*i+1] = byte(v),byte(v >> 8)
+	}
+
+	r,err := NewPcmS16leResampler(1, 8000, 44100)
+	if err != nil {
+		t.Error("invalid resampler, err is", err)
+		return
+	}
+	npcm,err := r.Resample(pcm)
+	if err != nil {
+		t.Error("resample failed, err is", err)
+		return
+	}
+	if r.Clipped() == 0 {
+		t.Error("invalid clipped", r.Clipped())
+	}
+
+	// No wrap-around to the opposite sign, the sample at the top of square is positive.
+	for i:=0; i<len(npcm)/2; i++ {
+		v := int16(npcm[2*i]) | (int16(npcm[2*i+1]) << 8)
+		x := float64(i) * 8000 / 44100
+		if p := math.Mod(x, 20); p > 2 && p < 8 && v < 0 {
+			t.Error("invalid sample at", i, v)
+			return
+		}
+	}
+
+	r.Reset()
+	if r.Clipped() != 0 {
+		t.Error("invalid clipped", r.Clipped())
+	}
+
+	// The float over the full-scale is clipped when convert to s16le.
+	if err = r.SetSampleFormat(FormatF32LE, FormatS16LE); err != nil {
+		t.Error("invalid format, err is", err)
+		return
+	}
+	f := make([]byte, 4*100)
+	for i:=0; i<100; i++ {
+		binary.LittleEndian.PutUint32(f[4*i:], math.Float32bits(1.5))
+	}
+	if _,err = r.Resample(f); err != nil || r.Clipped() == 0 {
+		t.Error("invalid clipped", r.Clipped(), err)
+	}
+}
+
+func TestDither(t *testing.T) {
+	if _,err := NewDither(DitherType(100), 0); err == nil {
+		t.Error("invalid dither")
+	}
+
+	// The f32le of 0.3LSB in s16le, which is always 0 by truncation.
+	f := make([]byte, 4*10000)
+	for i:=0; i<10000; i++ {
+		binary.LittleEndian.PutUint32(f[4*i:], math.Float32bits(0.3/32768))
+	}
+	convert := func(dtype DitherType, seed int64) (mean, lowpass float64) {
+		d,err := NewDither(dtype, seed)
+		if err != nil {
+			t.Error("invalid dither, err is", err)
+			return
+		}
+		npcm,err := ConvertSampleFormatDither(f, FormatF32LE, FormatS16LE, 1, d)
+		if err != nil {
+			t.Error("convert failed, err is", err)
+			return
+		}
+		// The mean of output, and the energy of error in low frequency, about 40HZ to 2.6KHZ.
+		n := len(npcm)/2
+		e := make([]float64, n)
+		for i := range e {
+			v := float64(int16(npcm[2*i]) | (int16(npcm[2*i+1]) << 8))
+			mean += v / float64(n)
+			e[i] = v - 0.3
+		}
+		for k:=10; k<600; k++ {
+			// The power of bin k by goertzel.
+			c := 2 * math.Cos(2*math.Pi*float64(k)/float64(n))
+			var s1,s2 float64
+			for _,x := range e {
+				s1,s2 = x + c*s1 - s2,s1
+			}
+			lowpass += s1*s1 + s2*s2 - c*s1*s2
+		}
+		return
+	}
+
+	if mean,_ := convert(DitherNone, 0); mean != 0 {
+		t.Error("invalid truncate", mean)
+	}
+	for _,dtype := range []DitherType{DitherRectangular, DitherTriangular, DitherLipshitz} {
+		if mean,_ := convert(dtype, 1); math.Abs(mean - 0.3) > 0.05 {
+			t.Error("invalid dither", dtype, mean)
+		}
+	}
+	if _,tpdf := convert(DitherTriangular, 1); true {
+		if _,shaped := convert(DitherLipshitz, 1); shaped > tpdf / 2 {
+			t.Error("invalid noise shaping", shaped, tpdf)
+		}
+	}
+
+	// The dither is deterministic for seed.
+	d0,_ := NewDither(DitherTriangular, 7)
+	d1,_ := NewDither(DitherTriangular, 7)
+	d2,_ := NewDither(DitherTriangular, 8)
+	o0,_ := ConvertSampleFormatDither(f, FormatF32LE, FormatS16LE, 2, d0)
+	o1,_ := ConvertSampleFormatDither(f, FormatF32LE, FormatS16LE, 2, d1)
+	o2,_ := ConvertSampleFormatDither(f, FormatF32LE, FormatS16LE, 2, d2)
+	if bytes.Compare(o0, o1) != 0 || bytes.Compare(o0, o2) == 0 {
+		t.Error("invalid seed")
+	}
+	if _,err := ConvertSampleFormatDither(f[:12], FormatF32LE, FormatS16LE, 2, d0); err == nil {
+		t.Error("invalid pcm")
+	}
+}
+
+func TestPcmS16leResample_Dither(t *testing.T) {
+	pcm := sinePcmS16le(440, 44100, 4410, 1000)
+	resample := func(d *Dither) []byte {
+		r,err := NewPcmS16leResampler(1, 44100, 48000)
+		if err != nil {
+			t.Error("invalid resampler, err is", err)
+			return nil
+		}
+		r.SetDither(d)
+		npcm,err := r.Resample(pcm)
+		if err != nil {
+			t.Error("resample failed, err is", err)
+		}
+		return npcm
+	}
+
+	d0,_ := NewDither(DitherNone, 0)
+	d1,_ := NewDither(DitherTriangular, 1)
+	d2,_ := NewDither(DitherTriangular, 1)
+	o,o0,o1,o2 := resample(nil),resample(d0),resample(d1),resample(d2)
+	if bytes.Compare(o, o0) != 0 {
+		t.Error("invalid none dither")
+	}
+	if bytes.Compare(o, o1) == 0 || bytes.Compare(o1, o2) != 0 {
+		t.Error("invalid dither")
+	}
+	if d := math.Abs(rmsPcmS16le(o1, 0) - rmsPcmS16le(o, 0)); d > 2 {
+		t.Error("invalid dither rms", d)
+	}
+}
+
+func TestPcmS16leResample_Writer(t *testing.T) {
+	pcm := sinePcmS16le(440, 44100, 4410+3, 10000)
+
+	// The expect output, resample all and flush.
+	r,err := NewPcmS16leResampler(1, 44100, 48000)
+	if err != nil {
+		t.Error("invalid resampler, err is", err)
+		return
+	}
+	expect,err := r.Resample(pcm)
+	if err != nil {
+		t.Error("resample failed, err is", err)
+		return
+	}
+	tail,err := r.Flush()
+	if err != nil {
+		t.Error("flush failed, err is", err)
+		return
+	}
+	expect = append(expect, tail...)
+	if len(expect) != 2*((len(pcm)/2*48000+44099)/44100) {
+		t.Error("invalid expect", len(expect))
+	}
+
+	// Write in any size, including the odd bytes.
+	cfg := Config{Channels: 1, SampleRate: 44100, NSampleRate: 48000}
+	for _,chunk := range []int{1, 3, 7, 100, 4096, len(pcm)} {
+		var b bytes.Buffer
+		w,err := NewWriter(&b, cfg)
+		if err != nil {
+			t.Error("invalid writer, err is", err)
+			return
+		}
+		for i:=0; i<len(pcm); i+=chunk {
+			n := chunk
+			if i+n > len(pcm) {
+				n = len(pcm)-i
+			}
+			if nn,err := w.Write(pcm[i:i+n]); err != nil || nn != n {
+				t.Error("write failed, err is", err, nn)
+				return
+			}
+		}
+		if err = w.Close(); err != nil {
+			t.Error("close failed, err is", err)
+			return
+		}
+		if bytes.Compare(b.Bytes(), expect) != 0 {
+			t.Error("invalid output", chunk, len(b.Bytes()), len(expect))
+		}
+		if _,err = w.Write(pcm); err == nil {
+			t.Error("write after close")
+		}
+	}
+
+	// The partial frame is error when close.
+	w,_ := NewWriter(&bytes.Buffer{}, Config{Channels: 2, SampleRate: 44100, NSampleRate: 48000})
+	w.Write(pcm[:3])
+	if err = w.Close(); err == nil {
+		t.Error("invalid partial frame")
+	}
+	if _,err = NewWriter(&bytes.Buffer{}, Config{Channels: 1}); err == nil {
+		t.Error("invalid config")
+	}
+
+	// The bypass writes the pcm.
+	var b bytes.Buffer
+	w,_ = NewWriter(&b, Config{Channels: 1, SampleRate: 44100, NSampleRate: 44100})
+	w.Write(pcm[:5])
+	w.Write(pcm[5:])
+	if err = w.Close(); err != nil || bytes.Compare(b.Bytes(), pcm) != 0 {
+		t.Error("invalid bypass", err, len(b.Bytes()))
+	}
+}
+
+// The reader returns a chunk for each read.
+type chunkReader struct {
+	b     []byte
+	chunk int
+}
+
+func (v *chunkReader) Read(p []byte) (n int, err error) {
+	if len(v.b) == 0 {
+		return 0,io.EOF
+	}
+	if len(p) > v.chunk {
+		p = p[:v.chunk]
+	}
+	n = copy(p, v.b)
+	v.b = v.b[n:]
+	return
+}
+
+func TestPcmS16leResample_Reader(t *testing.T) {
+	pcm := sinePcmS16le(440, 48000, 4800+1, 10000)
+	cfg := Config{Channels: 1, SampleRate: 48000, NSampleRate: 16000, NFormat: FormatF32LE}
+
+	var expect bytes.Buffer
+	w,err := NewWriter(&expect, cfg)
+	if err != nil {
+		t.Error("invalid writer, err is", err)
+		return
+	}
+	w.Write(pcm)
+	if err = w.Close(); err != nil {
+		t.Error("close failed, err is", err)
+		return
+	}
+	if expect.Len() != 4*1601 {
+		t.Error("invalid expect", expect.Len())
+	}
+
+	for _,chunk := range []int{1, 5, 1000, len(pcm)} {
+		r,err := NewReader(&chunkReader{b: pcm, chunk: chunk}, cfg)
+		if err != nil {
+			t.Error("invalid reader, err is", err)
+			return
+		}
+		npcm,err := io.ReadAll(r)
+		if err != nil {
+			t.Error("read failed, err is", err)
+			return
+		}
+		if bytes.Compare(npcm, expect.Bytes()) != 0 {
+			t.Error("invalid output", chunk, len(npcm))
+		}
+	}
+
+	// The partial frame is error when EOF.
+	r,_ := NewReader(bytes.NewReader(pcm[:3]), cfg)
+	if _,err = io.ReadAll(r); err == nil {
+		t.Error("invalid partial frame")
+	}
+}
+
+func TestPcmS16leResample_TinyFrames(t *testing.T) {
+	pcm := sinePcmS16le(440, 44100, 441, 10000)
+	for _,create := range []func() (ResampleSampleRate, error){
+		func() (ResampleSampleRate, error) { return NewPcmS16leResampler(1, 44100, 48000) },
+		func() (ResampleSampleRate, error) { return NewPcmS16leSincResampler(1, 44100, 48000, 32, 0.9) },
+	} {
+		r0,err := create()
+		if err != nil {
+			t.Error("invalid resampler, err is", err)
+			return
+		}
+		expect,_ := r0.Resample(pcm)
+		tail,_ := r0.Flush()
+		expect = append(expect, tail...)
+
+		// Resample sample by sample, with empty pcm between.
+		r1,_ := create()
+		var npcm []byte
+		for i:=0; i<len(pcm); i+=2 {
+			for _,b := range [][]byte{nil, pcm[i:i+2]} {
+				o,err := r1.Resample(b)
+				if err != nil {
+					t.Error("resample failed, err is", err)
+					return
+				}
+				npcm = append(npcm, o...)
+			}
+		}
+		tail,_ = r1.Flush()
+		npcm = append(npcm, tail...)
+
+		if bytes.Compare(npcm, expect) != 0 {
+			t.Error("invalid output", len(npcm), len(expect))
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	var pe *ParamError
+	if _,err := NewPcmS16leResampler(0, 44100, 48000); !errors.Is(err, ErrInvalidChannels) || !errors.As(err, &pe) || pe.Value != 0 {
+		t.Error("invalid channels error", err)
+	}
+	if _,err := NewPcmS16leResampler(1, 44100, 0); !errors.Is(err, ErrInvalidSampleRate) || err.Error() != "invalid nSampleRate=0" {
+		t.Error("invalid sample rate error", err)
+	}
+	if _,err := NewPcmS16leSincResampler(1, 44100, 48000, 31, 0.9); !errors.Is(err, ErrInvalidParameter) {
+		t.Error("invalid taps error", err)
+	}
+	if err := (SampleFormat{Bits: 12}).Validate(); !errors.Is(err, ErrInvalidFormat) {
+		t.Error("invalid format error", err)
+	}
+
+	var ue *UnalignedError
+	r,_ := NewPcmS16leResampler(2, 44100, 48000)
+	if _,err := r.Resample(make([]byte, 6)); !errors.Is(err, ErrUnaligned) || !errors.As(err, &ue) || ue.Size != 6 || ue.Align != 4 {
+		t.Error("invalid unaligned error", err)
+	}
+	if err := PcmS16leMono2Stereo(make([]byte, 3), make([]byte, 6)); !errors.As(err, &ue) || ue.Align != 2 {
+		t.Error("invalid unaligned error", err)
+	}
+
+	var be *BufferSizeError
+	if err := PcmS16leMono2Stereo(make([]byte, 4), make([]byte, 6)); !errors.Is(err, ErrBufferSize) || !errors.As(err, &be) || be.Size != 6 || be.Expect != 8 {
+		t.Error("invalid buffer size error", err)
+	}
+	if _,_,err := r.ResampleInto(make([]byte, 1), make([]byte, 400)); !errors.As(err, &be) || be.Size != 1 {
+		t.Error("invalid buffer size error", err)
+	}
+	if err := spline([]float64{1,2,3}, []float64{1,2,3,4}, []float64{1.5}, []float64{0}); !errors.As(err, &be) || be.Expect != 4 {
+		t.Error("invalid spline error", err)
+	}
+
+	var te *TooShortError
+	if err := spline([]float64{1,2,3,4}, []float64{1,2,3,4}, nil, nil); !errors.Is(err, ErrTooShort) || !errors.As(err, &te) || te.Min != 1 {
+		t.Error("invalid spline error", err)
+	}
+
+	w,_ := NewWriter(&bytes.Buffer{}, Config{Channels: 1, SampleRate: 44100, NSampleRate: 48000})
+	w.Close()
+	if _,err := w.Write(make([]byte, 2)); err != ErrClosed {
+		t.Error("invalid closed error", err)
+	}
+}
+
+func TestPcmS16leResample_Delay(t *testing.T) {
+	pcm := sinePcmS16le(440, 44100, 44100, 10000)
+	for _,create := range []func() (ResampleSampleRate, error){
+		func() (ResampleSampleRate, error) { return NewPcmS16leResampler(1, 44100, 48000) },
+		func() (ResampleSampleRate, error) { return NewPcmS16leSincResampler(1, 44100, 48000, 64, 0.9) },
+		func() (ResampleSampleRate, error) { return NewPcmS16lePolyphaseResampler(1, 44100, 48000, 32, 0.9) },
+	} {
+		r,err := create()
+		if err != nil {
+			t.Error("invalid resampler, err is", err)
+			return
+		}
+		if r.Delay(48000) != 0 || r.Latency() != 0 {
+			t.Error("invalid delay", r.Delay(48000))
+		}
+
+		// The predict is exact for any chunks, including the reconfigure.
+		var reconfigured bool
+		for i,n := 0,1; i+2*n<=len(pcm); i,n = i+2*n,(n*7+3)%1500 {
+			if i >= len(pcm)/2 && !reconfigured {
+				reconfigured = true
+				if err = r.Reconfigure(1, 32000, 48000); err != nil {
+					t.Error("reconfigure failed, err is", err)
+					return
+				}
+			}
+			expect := r.PredictOutputSamples(n)
+			npcm,err := r.Resample(pcm[i:i+2*n])
+			if err != nil {
+				t.Error("resample failed, err is", err)
+				return
+			}
+			if len(npcm)/2 != expect {
+				t.Error("invalid predict", i, n, expect, len(npcm)/2)
+				return
+			}
+		}
+
+		// The delay in output samples is the samples of flush.
+		delay,latency := r.Delay(48000),r.Latency()
+		if latency <= 0 || latency > 10*time.Millisecond {
+			t.Error("invalid latency", latency)
+		}
+		if d := math.Abs(float64(latency)/float64(time.Second) - float64(r.Delay(1000000))/1e6); d > 1e-6 {
+			t.Error("invalid latency", latency, r.Delay(1000000))
+		}
+		npcm,err := r.Flush()
+		if err != nil {
+			t.Error("flush failed, err is", err)
+			return
+		}
+		if int64(len(npcm)/2) != delay {
+			t.Error("invalid delay", delay, len(npcm)/2)
+		}
+		if r.Delay(48000) != 0 {
+			t.Error("invalid delay", r.Delay(48000))
+		}
+	}
+
+	// The delay while switching, the samples before the switch point are in the old rate.
+	for _,n := range []int{0, 5, 7, 11} {
+		r,_ := NewPcmS16leResampler(1, 44100, 48000)
+		if _,err := r.Resample(pcm[:2*1000]); err != nil {
+			t.Error("resample failed, err is", err)
+			return
+		}
+		if err := r.Reconfigure(1, 22050, 48000); err != nil {
+			t.Error("reconfigure failed, err is", err)
+			return
+		}
+		if _,err := r.Resample(pcm[:2*n]); err != nil {
+			t.Error("resample failed, err is", err)
+			return
+		}
+		delay := r.Delay(48000)
+		if npcm,err := r.Flush(); err != nil || int64(len(npcm)/2) != delay {
+			t.Error("invalid delay", n, delay, len(npcm)/2, err)
+		}
+	}
+}
+
+func TestPcmS16leResample_Frame(t *testing.T) {
+	r,err := NewPcmS16leSincResampler(1, 44100, 48000, 32, 0.9)
+	if err != nil {
+		t.Error("invalid resampler, err is", err)
+		return
+	}
+	if _,err = r.ResampleFrame(nil, 0, Timebase{0, 1}); err == nil {
+		t.Error("invalid timebase")
+	}
+
+	// The pts in output samples, which is the written samples from the start pts,
+	// the error is 1 because the pts of input is truncated.
+	tb := Timebase{1, 48000}
+	pcm := sinePcmS16le(440, 44100, 44100, 10000)
+	start,written,gap := int64(48000*10),int64(0),int64(48000)
+	var jumps int
+	check := func(frames []Frame) bool {
+		for _,f := range frames {
+			// The output after the gap jumps to the pts of input.
+			if d := f.PTS - start - gap - written; d >= -1 && d <= 1 {
+				start,jumps = start+gap,jumps+1
+			}
+			if d := f.PTS - start - written; f.Timebase != tb || d < -1 || d > 1 {
+				t.Error("invalid pts", f.PTS, start+written)
+				return false
+			}
+			written += int64(len(f.PCM) / 2)
+		}
+		return true
+	}
+
+	// The gap of 1s in pts at the middle.
+	for i:=0; i+1024<=44100; i+=1024 {
+		pts := start+int64(i)*48000/44100
+		if i >= 22050 {
+			pts = int64(48000*10)+gap+int64(i)*48000/44100
+		}
+		frames,err := r.ResampleFrame(pcm[2*i:2*i+2*1024], pts, tb)
+		if err != nil || !check(frames) {
+			t.Error("resample failed, err is", err)
+			return
+		}
+	}
+	if jumps != 1 {
+		t.Error("invalid jumps", jumps)
+	}
+
+	frames,err := r.FlushFrame()
+	if err != nil || len(frames) != 1 || !check(frames) {
+		t.Error("flush failed, err is", err, len(frames))
+	}
+
+	// The bypass keeps the pts.
+	r,_ = NewPcmS16leResampler(1, 44100, 44100)
+	if frames,err = r.ResampleFrame(pcm[:200], 100, Timebase{1, 1000}); err != nil || len(frames) != 1 || frames[0].PTS != 100 {
+		t.Error("invalid bypass", err, frames)
+	}
+}
+
+func TestAsyncResampler(t *testing.T) {
+	cfg := Config{Channels: 1, SampleRate: 44100, NSampleRate: 48000}
+	if _,err := NewAsyncResampler(cfg, AsyncOptions{MaxSoftComp: 1}); err == nil {
+		t.Error("invalid options")
+	}
+
+	// The source is 44000HZ in fact, the drift is 68ms in 30s, less than hard compensation,
+	// and the soft compensation works for the same rates.
+	for _,osr := range []int{48000, 44100} {
+		cfg.NSampleRate = osr
+		drift := func(opts AsyncOptions) (d float64, r *AsyncResampler) {
+			r,err := NewAsyncResampler(cfg, opts)
+			if err != nil {
+				t.Error("invalid resampler, err is", err)
+				return
+			}
+			pcm := sinePcmS16le(440, 44100, 1000, 10000)
+			for i:=0; i<44000*30; i+=1000 {
+				if _,err = r.Resample(pcm, int64(i), Timebase{1, 44000}); err != nil {
+					t.Error("resample failed, err is", err)
+					return
+				}
+			}
+			return 30 - r.NextPTS() - r.r.Latency().Seconds(),r
+		}
+		if d,r := drift(AsyncOptions{}); d < 0.05 || r.Inserted() != 0 || math.Abs(r.EffectiveSampleRate() - 44100) > 1e-6 {
+			t.Error("invalid drift", osr, d)
+		}
+		if d,r := drift(AsyncOptions{MaxSoftComp: 0.005}); math.Abs(d) > 0.005 || r.Inserted() != 0 || r.Dropped() != 0 {
+			t.Error("invalid soft compensation", osr, d, r.EffectiveSampleRate())
+		} else if esr := r.EffectiveSampleRate(); esr < 43900 || esr > 44100 {
+			t.Error("invalid effective rate", osr, esr)
+		}
+	}
+	cfg.NSampleRate = 48000
+
+	// The gap of 0.5s is filled with silence, the overlap of 0.15s is dropped.
+	r,err := NewAsyncResampler(cfg, AsyncOptions{})
+	if err != nil {
+		t.Error("invalid resampler, err is", err)
+		return
+	}
+	pcm := sinePcmS16le(440, 44100, 8820, 10000)
+	for _,pts := range []int64{1000, 1200, 1900, 1950} {
+		if _,err = r.Resample(pcm, pts, Timebase{1, 1000}); err != nil {
+			t.Error("resample failed, err is", err)
+			return
+		}
+	}
+	if n := r.Inserted(); n < 22050-100 || n > 22050+100 {
+		t.Error("invalid inserted", n)
+	}
+	if n := r.Dropped(); n < 6615-100 || n > 6615+100 {
+		t.Error("invalid dropped", n)
+	}
+	if d := 2.15 - r.NextPTS() - r.r.Latency().Seconds(); math.Abs(d) > 0.001 {
+		t.Error("invalid drift", d)
+	}
+}
+
+func TestPcmS16leResample_Ratio(t *testing.T) {
+	pcm := sinePcmS16le(440, 44100, 44100, 10000)
+	for _,create := range []func() (ResampleSampleRate, error){
+		func() (ResampleSampleRate, error) { return NewPcmS16leResampler(1, 44100, 48000) },
+		func() (ResampleSampleRate, error) { return NewPcmS16leSincResampler(1, 44100, 48000, 64, 0.9) },
+		func() (ResampleSampleRate, error) { return NewPcmS16lePolyphaseResampler(1, 44100, 48000, 32, 0.9) },
+	} {
+		r,err := create()
+		if err != nil {
+			t.Error("invalid resampler, err is", err)
+			return
+		}
+		for _,ratio := range []float64{0, -1, math.NaN(), math.Inf(1), 1.0/1000, 1000} {
+			if err := r.SetRatio(ratio); !errors.Is(err, ErrInvalidParameter) {
+				t.Error("invalid ratio", ratio, err)
+			}
+		}
+
+		// Set to the nominal ratio, the output is bit-identical.
+		expect,err := r.Resample(pcm)
+		if err != nil {
+			t.Error("resample failed, err is", err)
+			return
+		}
+		r.Reset()
+		if err = r.SetOutputRate(48000); err != nil {
+			t.Error("set output rate failed, err is", err)
+			return
+		}
+		if npcm,err := r.Resample(pcm); err != nil || !bytes.Equal(npcm, expect) {
+			t.Error("invalid nominal ratio", err)
+			return
+		}
+
+		// Sweep the ratio for each chunk, the output count tracks the ratio,
+		// and the tone is continuous without clicks.
+		r.Reset()
+		var out []byte
+		var expectSamples float64
+		for i,n := 0,0; i<len(pcm); i,n = i+2*441,n+1 {
+			ratio := 48000.0/44100 * (1 + 0.02*math.Sin(float64(n)/10))
+			if err = r.SetRatio(ratio); err != nil {
+				t.Error("set ratio failed, err is", err)
+				return
+			}
+			if math.Abs(r.Ratio() - ratio) > 1e-6 {
+				t.Error("invalid ratio", ratio, r.Ratio())
+			}
+			npcm,err := r.Resample(pcm[i:i+2*441])
+			if err != nil {
+				t.Error("resample failed, err is", err)
+				return
+			}
+			out = append(out, npcm...)
+			expectSamples += 441*ratio
+		}
+		if d := math.Abs(float64(len(out)/2) - expectSamples); d > 64 {
+			t.Error("invalid output samples", len(out)/2, expectSamples)
+		}
+		var maxDiff int
+		for i:=2*256; i+3<len(out); i+=2 {
+			a := int(int16(out[i]) | (int16(out[i+1]) << 8))
+			b := int(int16(out[i+2]) | (int16(out[i+3]) << 8))
+			if d := a-b; d > maxDiff {
+				maxDiff = d
+			} else if -d > maxDiff {
+				maxDiff = -d
+			}
+		}
+		// The max step of 440Hz at 48kHz with 10000 amplitude is about 576.
+		if maxDiff > 700 {
+			t.Error("invalid discontinuity", maxDiff)
+		}
+
+		// Reset restores the nominal ratio.
+		r.Reset()
+		if r.Ratio() != 48000.0/44100 {
+			t.Error("invalid ratio", r.Ratio())
+		}
+	}
+
+	// The ratio works for the same rates, which is not bypassed.
+	pcm = sinePcmS16le(440, 48000, 4800, 10000)
+	for _,kernel := range []KernelFunc{nil, QualityHigh.Kernel()} {
+		r,err := NewResampler(Config{Channels: 1, SampleRate: 48000, NSampleRate: 48000, Kernel: kernel})
+		if err != nil {
+			t.Error("invalid resampler, err is", err)
+			return
+		}
+		if err = r.SetRatio(1.01); err != nil || math.Abs(r.Ratio() - 1.01) > 1e-6 {
+			t.Error("set ratio failed", r.Ratio(), err)
+			return
+		}
+		expect := r.PredictOutputSamples(4800)
+		npcm,err := r.Resample(pcm)
+		if err != nil || len(npcm)/2 != expect {
+			t.Error("invalid predict", expect, len(npcm)/2, err)
+		}
+		flushed,_ := r.Flush()
+		if n := (len(npcm) + len(flushed))/2; n < 4847 || n > 4849 {
+			t.Error("invalid output samples", n)
+		}
+		if npcm,err := r.ResampleFloat64(make([]float64, 4800)); err != nil || len(npcm) == 4800 {
+			t.Error("invalid float", len(npcm), err)
+		}
+	}
+
+	// The polyphase interpolates between phases, where L is 1 for 48000 to 24000.
+	in := make([]float64, 48000)
+	for i := range in {
+		in[i] = 0.5 * math.Sin(2*math.Pi*1000*float64(i)/48000)
+	}
+	for _,v := range []struct{
+		kernel KernelFunc
+		snr float64
+	}{
+		{QualityHigh.Kernel(), 120}, {KernelPolyphase(64, 0.9), 90},
+	} {
+		r,err := NewResampler(Config{Channels: 1, SampleRate: 48000, NSampleRate: 24000, Kernel: v.kernel})
+		if err != nil {
+			t.Error("invalid resampler, err is", err)
+			return
+		}
+		if err = r.SetRatio(0.5001); err != nil {
+			t.Error("set ratio failed, err is", err)
+			return
+		}
+		out,err := r.ResampleFloat64(in)
+		if err != nil {
+			t.Error("resample failed, err is", err)
+			return
+		}
+
+		// The output sample i is at the input position i/ratio.
+		step := math.Floor(srRatioDen/0.5001 + 0.5) / srRatioDen
+		var signal,noise float64
+		for i:=1024; i<len(out)-1024; i++ {
+			x := 0.5 * math.Sin(2*math.Pi*1000*float64(i)*step/48000)
+			signal += x*x
+			noise += (out[i]-x)*(out[i]-x)
+		}
+		if snr := 10*math.Log10(signal/noise); snr < v.snr {
+			t.Error("invalid snr", snr, v.snr)
+		}
+	}
+
+	// Set the ratio while switching, the samples before the switch point keep the old step.
+	r,_ := NewPcmS16leResampler(1, 44100, 48000)
+	if _,err := r.Resample(sinePcmS16le(440, 44100, 1000, 10000)); err != nil {
+		t.Error("resample failed, err is", err)
+		return
+	}
+	if err := r.Reconfigure(1, 22050, 48000); err != nil {
+		t.Error("reconfigure failed, err is", err)
+		return
+	}
+	if err := r.SetRatio(48000.0/22050 * 1.0001); err != nil {
+		t.Error("set ratio failed, err is", err)
+		return
+	}
+	if d := r.Delay(48000); d != 17 {
+		t.Error("invalid delay", d)
+	}
+	if npcm,err := r.Flush(); err != nil || len(npcm)/2 != 17 {
+		t.Error("invalid flush", len(npcm)/2, err)
+	}
+}
+
+// The user kernel, which is the linear interpolation.
+type testLinearKernel struct {
+}
+
+func (v *testLinearKernel) Taps() (before, after int) {
+	return 0,1
+}
+
+func (v *testLinearKernel) Interpolate(w []float64, frac float64) (float64, error) {
+	return (1-frac)*w[0] + frac*w[1],nil
+}
+
+func TestKernel(t *testing.T) {
+	pcm := sinePcmS16le(1000, 44100, 4410, 10000)
+
+	// The SNR of output, compare to the ideal sine at the output rate.
+	snr := func(kernel KernelFunc) float64 {
+		r,err := NewResampler(Config{Channels: 1, SampleRate: 44100, NSampleRate: 48000, Kernel: kernel})
+		if err != nil {
+			t.Error("invalid resampler, err is", err)
+			return 0
+		}
+		npcm,err := r.Resample(pcm)
+		if err != nil {
+			t.Error("resample failed, err is", err)
+			return 0
+		}
+		var signal,noise float64
+		for i:=64; i<len(npcm)/2-64; i++ {
+			y := float64(int16(npcm[2*i]) | (int16(npcm[2*i+1]) << 8))
+			x := 10000 * math.Sin(2*math.Pi*1000*float64(i)/48000)
+			signal += x*x
+			noise += (y-x)*(y-x)
+		}
+		return 10*math.Log10(signal/noise)
+	}
+
+	nearest,linear,cubic := snr(KernelNearest),snr(KernelLinear),snr(KernelCubic)
+	if nearest < 15 || linear < 40 || cubic < 50 || nearest >= linear || linear >= cubic {
+		t.Error("invalid snr", nearest, linear, cubic)
+	}
+	for _,kernel := range []KernelFunc{KernelSpline, KernelLanczos(3), KernelSinc(64, 0.9), KernelPolyphase(32, 0.9)} {
+		if v := snr(kernel); v < 50 {
+			t.Error("invalid snr", v)
+		}
+	}
+	if v := snr(func(isr, osr int) (Kernel, error) { return &testLinearKernel{},nil }); math.Abs(v - linear) > 0.1 {
+		t.Error("invalid user kernel", v, linear)
+	}
+
+	// The default kernel is spline, bit-identical to FFMPEG.
+	for _,kernel := range []KernelFunc{nil, KernelSpline} {
+		r0,_ := NewPcmS16leResampler(1, 44100, 48000)
+		r1,err := NewPcmS16leKernelResampler(1, 44100, 48000, kernel)
+		if err != nil {
+			t.Error("invalid resampler, err is", err)
+			return
+		}
+		expect,_ := r0.Resample(pcm)
+		if npcm,err := r1.Resample(pcm); err != nil || !bytes.Equal(npcm, expect) {
+			t.Error("invalid spline", err)
+		}
+	}
+
+	// The taps of kernels, stretched when downsampling.
+	for _,v := range []struct{
+		kernel KernelFunc
+		isr, osr int
+		before, after int
+	}{
+		{KernelNearest, 44100, 48000, 0, 1},
+		{KernelLinear, 44100, 48000, 0, 1},
+		{KernelCubic, 44100, 48000, 1, 2},
+		{KernelSpline, 44100, 48000, 0, 3},
+		{KernelLanczos(3), 44100, 48000, 2, 3},
+		{KernelLanczos(3), 48000, 24000, 5, 6},
+		{KernelSinc(8, 0.9), 8000, 16000, 3, 4},
+	} {
+		k,err := v.kernel(v.isr, v.osr)
+		if err != nil {
+			t.Error("invalid kernel, err is", err)
+			continue
+		}
+		if before,after := k.Taps(); before != v.before || after != v.after {
+			t.Error("invalid taps", before, after, v)
+		}
+	}
+
+	// The kernel passes through the samples.
+	w := []float64{7, 9, 2, 5, 3, 1}
+	for _,kernel := range []KernelFunc{KernelNearest, KernelLinear, KernelCubic, KernelSpline, KernelLanczos(2)} {
+		k,_ := kernel(44100, 48000)
+		before,after := k.Taps()
+		if y,err := k.Interpolate(w[2-before:3+after], 0); err != nil || math.Abs(y - 2) > 1e-9 {
+			t.Error("invalid interpolate", before, after, y, err)
+		}
+	}
+
+	// The kernel is created again for the new rates.
+	var created []int
+	kernel := func(isr, osr int) (Kernel, error) {
+		created = append(created, isr)
+		return KernelLanczos(2)(isr, osr)
+	}
+	if r,err := NewPcmS16leKernelResampler(1, 44100, 48000, kernel); err != nil {
+		t.Error("invalid resampler, err is", err)
+	} else if err = r.Reconfigure(1, 32000, 48000); err != nil || len(created) != 2 || created[1] != 32000 {
+		t.Error("invalid reconfigure", created, err)
+	}
+
+	for _,kernel := range []KernelFunc{
+		KernelLanczos(0), KernelSinc(3, 0.9), KernelSinc(64, 0), KernelPolyphase(64, 1.1),
+		func(isr, osr int) (Kernel, error) { return nil,nil },
+	} {
+		if _,err := NewResampler(Config{Channels: 1, SampleRate: 44100, NSampleRate: 48000, Kernel: kernel}); !errors.Is(err, ErrInvalidParameter) {
+			t.Error("invalid kernel", err)
+		}
+	}
+}
+
+// Measure the kernel by float64 mono, the snr is the SNR in dB of 1kHz tone from 44100 to 48000,
+// and the alias is the attenuation in dB of 23kHz tone from 48000 to 44100.
+func measureKernel(kernel KernelFunc) (snr, alias float64, err error) {
+	tone := func(freq float64, isr, osr int) (in, out []float64, err error) {
+		in = make([]float64, isr)
+		for i := range in {
+			in[i] = 0.5 * math.Sin(2*math.Pi*freq*float64(i)/float64(isr))
+		}
+
+		var r ResampleSampleRate
+		if r,err = NewResampler(Config{Channels: 1, SampleRate: isr, NSampleRate: osr, Kernel: kernel}); err != nil {
+			return
+		}
+		out,err = r.ResampleFloat64(in)
+		return
+	}
+
+	// Ignore the edges, which is affected by the kernel and flush.
+	power := func(v []float64) (sum float64) {
+		for _,s := range v[1024:len(v)-1024] {
+			sum += s*s
+		}
+		return
+	}
+
+	in,out,err := tone(1000, 44100, 48000)
+	if err != nil {
+		return
+	}
+	noise := make([]float64, len(out))
+	for i,s := range out {
+		noise[i] = s - 0.5 * math.Sin(2*math.Pi*1000*float64(i)/48000)
+	}
+	snr = 10*math.Log10(power(in)/float64(len(in)) / (power(noise)/float64(len(noise))))
+
+	if in,out,err = tone(23000, 48000, 44100); err != nil {
+		return
+	}
+	alias = 10*math.Log10(power(in)/float64(len(in)) / (power(out)/float64(len(out))))
+	return
+}
+
+// The benchmark of quality presets, for example:
+//		go test -run=NONE -bench=Quality
+// which reports the SNR, alias attenuation and CPU cost of each preset.
+func BenchmarkQuality(b *testing.B) {
+	for _,v := range []struct{
+		name string
+		kernel KernelFunc
+	}{
+		{QualityFastest.String(), QualityFastest.Kernel()},
+		{QualityLow.String(), QualityLow.Kernel()},
+		{QualityMedium.String(), QualityMedium.Kernel()},
+		{QualityHigh.String(), QualityHigh.Kernel()},
+		{QualityVeryHigh.String(), QualityVeryHigh.Kernel()},
+		{"spline", KernelSpline},
+	} {
+		b.Run(v.name, func(b *testing.B) {
+			snr,alias,err := measureKernel(v.kernel)
+			if err != nil {
+				b.Fatal("measure failed, err is", err)
+			}
+
+			// Resample 1s of stereo pcm from 44100 to 48000.
+			r,err := NewResampler(Config{Channels: 2, SampleRate: 44100, NSampleRate: 48000, Kernel: v.kernel})
+			if err != nil {
+				b.Fatal("invalid resampler, err is", err)
+			}
+			pcm := make([]float64, 2*44100)
+			for i := range pcm {
+				pcm[i] = 0.5 * math.Sin(2*math.Pi*1000*float64(i/2)/44100)
+			}
+
+			b.ResetTimer()
+			start := time.Now()
+			for i:=0; i<b.N; i++ {
+				if _,err := r.ResampleFloat64(pcm); err != nil {
+					b.Fatal("resample failed, err is", err)
+				}
+			}
+			elapsed := time.Since(start)
+			b.StopTimer()
+
+			b.ReportMetric(snr, "snr-dB")
+			b.ReportMetric(alias, "alias-dB")
+			b.ReportMetric(float64(elapsed.Nanoseconds())/float64(b.N)/44100, "ns/frame")
+		})
+	}
+}
+
+func TestQuality(t *testing.T) {
+	// The SNR and alias attenuation meet the targets of presets.
+	var last float64
+	for _,q := range []Quality{QualityFastest, QualityLow, QualityMedium, QualityHigh, QualityVeryHigh} {
+		p,ok := q.Params()
+		if !ok || p.Taps < 2 {
+			t.Error("invalid params", q, p)
+			continue
+		}
+		snr,alias,err := measureKernel(q.Kernel())
+		if err != nil {
+			t.Error("measure failed, err is", q, err)
+			continue
+		}
+		if snr <= last || alias < p.Stopband {
+			t.Error("invalid quality", q, snr, alias, p)
+		}
+		last = snr
+	}
+
+	if v := fmt.Sprint(QualityFastest, QualityVeryHigh, Quality(0)); v != "fastest veryhigh unknown(0)" {
+		t.Error("invalid string", v)
+	}
+	if _,ok := Quality(0).Params(); ok {
+		t.Error("invalid params")
+	}
+	for _,q := range []Quality{-1, QualityVeryHigh + 1} {
+		if _,err := NewResampler(Config{Channels: 1, SampleRate: 44100, NSampleRate: 48000, Quality: q}); !errors.Is(err, ErrInvalidParameter) {
+			t.Error("invalid quality", q, err)
+		}
+	}
+
+	// The kernel overrides the quality, and the polyphase falls back to sinc when too many phases.
+	pcm := sinePcmS16le(1000, 44100, 4410, 10000)
+	for _,v := range []struct{
+		cfg Config
+		expect KernelFunc
+	}{
+		{Config{Quality: QualityLow}, KernelCubic},
+		{Config{Quality: QualityMedium, Kernel: KernelLinear}, KernelLinear},
+		{Config{Quality: QualityMedium}, KernelPolyphase(64, 0.9)},
+	} {
+		v.cfg.Channels,v.cfg.SampleRate,v.cfg.NSampleRate = 1,44100,48000
+		r0,err := NewResampler(v.cfg)
+		if err != nil {
+			t.Error("invalid resampler, err is", err)
+			continue
+		}
+		r1,_ := NewPcmS16leKernelResampler(1, 44100, 48000, v.expect)
+		npcm,_ := r0.Resample(pcm)
+		expect,_ := r1.Resample(pcm)
+		if v.cfg.Quality == QualityMedium && v.cfg.Kernel == nil {
+			// The beta of preset is different, so the output is similar, not identical.
+			if len(npcm) != len(expect) || bytes.Equal(npcm, expect) {
+				t.Error("invalid quality", v.cfg.Quality)
+			}
+		} else if !bytes.Equal(npcm, expect) {
+			t.Error("invalid quality", v.cfg.Quality)
+		}
+	}
+	if k,err := QualityHigh.Kernel()(44100, 48001); err != nil {
+		t.Error("invalid kernel, err is", err)
+	} else if _,ok := k.(*sincInterpolator); !ok {
+		t.Errorf("invalid kernel %T", k)
+	}
+
+	// The filter bank is shared by the resamplers of the same rates and preset.
+	k0,err0 := QualityVeryHigh.Kernel()(44100, 48000)
+	k1,err1 := QualityVeryHigh.Kernel()(88200, 96000)
+	k2,err2 := QualityHigh.Kernel()(44100, 48000)
+	if err0 != nil || err1 != nil || err2 != nil || k0 != k1 || k0 == k2 {
+		t.Error("invalid shared bank", err0, err1, err2)
+	}
+
+	// The shared banks is bounded, evict the least recently used one, and never share the large one.
+	b0,_ := newPolyphaseBank(44100, 48000, 32, 0.9)
+	b1,_ := newPolyphaseBank(8000, 16000, 32, 0.9)
+	b2,_ := newPolyphaseBank(16000, 48000, 32, 0.9)
+	c := newPolyphaseCache(4*b0.bytes())
+	k := func(b *polyphaseBank) polyphaseKey {
+		return polyphaseKey{up: b.up, down: b.down}
+	}
+	if c.store(k(b0), b0) != b0 || c.store(k(b1), b1) != b1 || c.store(k(b2), b2) != b2 || c.size != b0.bytes()+b1.bytes()+b2.bytes() {
+		t.Error("invalid cache", c.size)
+	}
+	if b,ok := c.load(k(b1)); !ok || b != b1 {
+		t.Error("invalid cache", ok)
+	}
+	if b,_ := newPolyphaseBank(44100, 48000, 32, 0.95); c.store(k(b0), b) != b0 {
+		t.Error("invalid shared bank")
+	}
+	for i:=0; i<4; i++ {
+		b := &polyphaseBank{up: uint64(i), coeffs: make([]float64, len(b0.coeffs))}
+		c.store(k(b), b)
+	}
+	if _,ok := c.load(k(b2)); ok || c.size > c.max || c.lru.Len() != len(c.banks) {
+		t.Error("invalid evict", c.size, c.lru.Len())
+	}
+	if b := (&polyphaseBank{up: 7, coeffs: make([]float64, len(b0.coeffs)+1)}); c.store(k(b), b) != b {
+		t.Error("invalid large bank")
+	} else if _,ok := c.load(k(b)); ok {
+		t.Error("invalid large bank")
+	}
+}
+
+func TestResampleBuffer(t *testing.T) {
+	// The output is exactly round(n*osr/isr) samples.
+	for _,v := range []struct{
+		isr, osr int
+	}{
+		{44100, 48000}, {48000, 44100}, {8000, 44100}, {44100, 8000}, {16000, 48000}, {48000, 16000},
+	} {
+		for _,n := range []int{0, 1, 2, 3, 7, 160, 441, 4410} {
+			for _,cfg := range []Config{
+				{Channels: 2},
+				{Channels: 2, Kernel: KernelLinear},
+				{Channels: 2, Quality: QualityMedium},
+			} {
+				cfg.SampleRate,cfg.NSampleRate = v.isr,v.osr
+				npcm,err := ResampleBuffer(make([]byte, 4*n), cfg)
+				if err != nil {
+					t.Error("resample failed, err is", err)
+					return
+				}
+				if expect := (2*n*v.osr + v.isr) / (2*v.isr); len(npcm) != 4*expect {
+					t.Error("invalid samples", v, n, len(npcm)/4, expect)
+				}
+			}
+		}
+	}
+
+	// The output is aligned with the input, including the edges.
+	pcm := sinePcmS16le(1000, 44100, 4410, 10000)
+	npcm,err := ResampleBuffer(pcm, Config{Channels: 1, SampleRate: 44100, NSampleRate: 48000, Quality: QualityHigh})
+	if err != nil {
+		t.Error("resample failed, err is", err)
+		return
+	}
+	if len(npcm) != 2*4800 {
+		t.Error("invalid samples", len(npcm)/2)
+	}
+	for i:=0; i<len(npcm)/2; i++ {
+		y := float64(int16(npcm[2*i]) | (int16(npcm[2*i+1]) << 8))
+		x := 10000 * math.Sin(2*math.Pi*1000*float64(i)/48000)
+		if d := math.Abs(y-x); d > 2 && (i < 4700 || d > 100) {
+			t.Error("invalid sample", i, x, y)
+			break
+		}
+	}
+
+	// The middle is the same to the streaming resampler, for the same kernel.
+	r,_ := NewPcmS16leResampler(1, 44100, 48000)
+	expect,_ := r.Resample(pcm)
+	if npcm,err = ResampleBuffer(pcm, Config{Channels: 1, SampleRate: 44100, NSampleRate: 48000}); err != nil {
+		t.Error("resample failed, err is", err)
+	} else if !bytes.Equal(npcm[2*32:len(expect)-2*32], expect[2*32:len(expect)-2*32]) {
+		t.Error("invalid alignment")
+	}
+
+	// The channels are resampled independently, and the same rates only convert format.
+	stereo := make([]byte, 2*len(pcm))
+	for i:=0; i<len(pcm)/2; i++ {
+		copy(stereo[4*i:], pcm[2*i:2*i+2])
+	}
+	if npcm,err = ResampleBuffer(stereo, Config{Channels: 2, SampleRate: 44100, NSampleRate: 48000}); err != nil {
+		t.Error("resample failed, err is", err)
+	} else if mono,_ := ResampleBuffer(pcm, Config{Channels: 1, SampleRate: 44100, NSampleRate: 48000}); len(npcm) != 2*len(mono) {
+		t.Error("invalid samples", len(npcm), len(mono))
+	} else {
+		for i:=0; i<len(mono)/2; i++ {
+			if !bytes.Equal(npcm[4*i:4*i+2], mono[2*i:2*i+2]) || npcm[4*i+2] != 0 || npcm[4*i+3] != 0 {
+				t.Error("invalid channel", i)
+				break
+			}
+		}
+	}
+	if npcm,err = ResampleBuffer(pcm, Config{Channels: 1, SampleRate: 44100, NSampleRate: 44100}); err != nil || !bytes.Equal(npcm, pcm) {
+		t.Error("invalid bypass", err)
+	}
+	if npcm,err = ResampleBuffer(pcm, Config{Channels: 1, SampleRate: 44100, NSampleRate: 44100, NFormat: FormatS32LE}); err != nil || len(npcm) != 2*len(pcm) {
+		t.Error("invalid bypass", err)
+	}
+
+	if _,err = ResampleBuffer(pcm[:3], Config{Channels: 1, SampleRate: 44100, NSampleRate: 48000}); !errors.Is(err, ErrUnaligned) {
+		t.Error("invalid pcm", err)
+	}
+	if _,err = ResampleBuffer(pcm, Config{Channels: 1, SampleRate: 0, NSampleRate: 48000}); !errors.Is(err, ErrInvalidSampleRate) {
+		t.Error("invalid rate", err)
+	}
+}
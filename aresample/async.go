@@ -0,0 +1,174 @@
+// The MIT License (MIT)
+//
+// Copyright (c) 2016 winlin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+
+// The PCM resample.
+package aresample
+
+import "math"
+
+// The options of async resampler, like the aresample=async=1 of ffmpeg.
+type AsyncOptions struct {
+	// The min gap in seconds between the timestamp and the output timeline to insert silence
+	// or drop samples, the hard compensation, default to 0.1s like min_hard_comp.
+	MinHardComp float64
+	// The min gap in seconds to adjust the ratio, the soft compensation, default to 0.001s
+	// like min_comp.
+	MinSoftComp float64
+	// The max ratio to adjust for soft compensation, for example, 0.001 allows to stretch or
+	// squeeze 1ms per second, the gap is compensated in about 1s, 0 to disable, like max_soft_comp.
+	// @remark the ratio is adjusted by SetRatio, so the polyphase kernel falls back to the
+	// 		windowed-sinc between its phases, which costs more CPU.
+	MaxSoftComp float64
+}
+
+// The async resampler, which compensates the drift between the input timestamps and
+// the output timeline, for example, the 44100HZ source which is really 44087HZ.
+// The soft compensation slightly adjusts the ratio by SetRatio, and the hard compensation
+// inserts silence for gap or drops samples for overlap.
+type AsyncResampler struct {
+	r        ResampleSampleRate
+	opts     AsyncOptions
+	channels int
+	isr      int    // The nominal input sample rate.
+	osr      int    // The output sample rate.
+	ratio    float64 // The ratio of soft compensation.
+	format   SampleFormat // The format of input pcm.
+	nframe   int     // The bytes of output frame.
+	start    float64 // The pts in seconds of first output sample.
+	started  bool
+	written  uint64 // Total output samples.
+	inserted uint64 // Total silence samples inserted.
+	dropped  uint64 // Total input samples dropped.
+}
+
+// Create an async resampler by cfg and opts.
+func NewAsyncResampler(cfg Config, opts AsyncOptions) (*AsyncResampler, error) {
+	r,err := NewResampler(cfg)
+	if err != nil {
+		return nil,err
+	}
+
+	if opts.MinHardComp <= 0 {
+		opts.MinHardComp = 0.1
+	}
+	if opts.MinSoftComp <= 0 {
+		opts.MinSoftComp = 0.001
+	}
+	if opts.MaxSoftComp < 0 || opts.MaxSoftComp >= 1 {
+		return nil,&ParamError{Name: "maxSoftComp", Value: opts.MaxSoftComp, Err: ErrInvalidParameter}
+	}
+
+	format,nformat := cfg.formats()
+	return &AsyncResampler{
+		r: r, opts: opts, channels: cfg.Channels, format: format,
+		nframe: nformat.BytesPerSample() * cfg.Channels,
+		isr: cfg.SampleRate, osr: cfg.NSampleRate, ratio: r.Ratio(),
+	},nil
+}
+
+// Resample the pcm with timestamp pts in tb, compensate the drift to the output timeline,
+// which starts at the pts of first pcm, so the output is continuous and in sync.
+func (v *AsyncResampler) Resample(pcm []byte, pts int64, tb Timebase) (npcm []byte, err error) {
+	if tb.Num <= 0 || tb.Den <= 0 {
+		return nil,&ParamError{Name: "timebase", Value: tb, Err: ErrInvalidParameter}
+	}
+	frame := v.format.BytesPerSample() * v.channels
+	if (len(pcm) % frame) != 0 {
+		return nil,&UnalignedError{Name: "pcm", Size: len(pcm), Align: frame}
+	}
+
+	seconds := tb.seconds(pts)
+	if !v.started {
+		v.start,v.started = seconds,true
+	}
+
+	// The drift of pts to the expected, the time of the output timeline when
+	// the first sample of pcm is outputed, after the cached samples.
+	drift := seconds - v.NextPTS() - v.r.Latency().Seconds()
+
+	// Hard compensation, insert silence for gap, or drop samples for overlap.
+	if math.Abs(drift) > v.opts.MinHardComp {
+		n := int(math.Floor(math.Abs(drift)*float64(v.isr) + 0.5))
+		if drift > 0 {
+			silence := make([]byte, n*frame)
+			for i:=0; i<len(silence); i+=v.format.BytesPerSample() {
+				v.format.encode(silence[i:i+v.format.BytesPerSample()], 0)
+			}
+			pcm = append(silence, pcm...)
+			v.inserted += uint64(n)
+		} else {
+			if n > len(pcm)/frame {
+				n = len(pcm)/frame
+			}
+			pcm = pcm[n*frame:]
+			v.dropped += uint64(n)
+		}
+		drift = 0
+	}
+
+	// Soft compensation, adjust the ratio to compensate the drift in about 1s.
+	ratio := float64(v.osr)/float64(v.isr)
+	if v.opts.MaxSoftComp > 0 && math.Abs(drift) > v.opts.MinSoftComp {
+		ratio *= 1 + math.Max(-v.opts.MaxSoftComp, math.Min(v.opts.MaxSoftComp, drift))
+	}
+	if ratio != v.ratio {
+		if err = v.r.SetRatio(ratio); err != nil {
+			return nil,err
+		}
+		v.ratio = ratio
+	}
+
+	if npcm,err = v.r.Resample(pcm); err != nil {
+		return nil,err
+	}
+	v.written += uint64(len(npcm) / v.nframe)
+	return
+}
+
+// Flush the cached samples.
+func (v *AsyncResampler) Flush() (npcm []byte, err error) {
+	if npcm,err = v.r.Flush(); err != nil {
+		return nil,err
+	}
+	v.written += uint64(len(npcm) / v.nframe)
+	return
+}
+
+// The pts in seconds of next output sample, the output timeline.
+func (v *AsyncResampler) NextPTS() float64 {
+	return v.start + float64(v.written)/float64(v.osr)
+}
+
+// The effective input sample rate of soft compensation.
+func (v *AsyncResampler) EffectiveSampleRate() float64 {
+	return float64(v.osr) / v.ratio
+}
+
+// Total silence samples inserted by hard compensation.
+func (v *AsyncResampler) Inserted() uint64 {
+	return v.inserted
+}
+
+// Total input samples dropped by hard compensation.
+func (v *AsyncResampler) Dropped() uint64 {
+	return v.dropped
+}
@@ -0,0 +1,114 @@
+// The MIT License (MIT)
+//
+// Copyright (c) 2016 winlin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+
+// The PCM resample.
+package aresample
+
+import "math/bits"
+
+// Resample the whole pcm in one shot, for example, to transcode VOD with the entire file in memory,
+// which returns exactly round(n*osr/isr) samples, where n is the input samples, and the output
+// sample k is aligned at the input position k*isr/osr, the same to the timeline of input.
+// The kernel interpolates at the position of output sample with the lookahead on both sides,
+// so there is no filter delay, and the edges are extended by the odd reflection 2*x[0]-x[k],
+// which is continuous in value and slope, so there is no click at the start and end,
+// while the streaming resampler pads silence and outputs ceil(n*osr/isr) samples by Flush.
+// @remark the cfg is the same to NewResampler, for example, the format, kernel and quality.
+func ResampleBuffer(pcm []byte, cfg Config) (npcm []byte, err error) {
+	r,err := NewResampler(cfg)
+	if err != nil {
+		return nil,err
+	}
+
+	v := r.(*srResampler)
+	if err = v.validate(pcm); err != nil {
+		return nil,err
+	}
+
+	// Bypass when rates are the same, only convert the format.
+	if v.isr == v.osr {
+		npcm = make([]byte, len(pcm)/v.format.BytesPerSample()*v.nformat.BytesPerSample())
+		resample_convert(npcm, pcm, v.format, v.nformat, v.channels, v.dither)
+		return npcm,nil
+	}
+
+	// The output samples is round(n*osr/isr), that is floor((2*n*osr+isr)/(2*isr)).
+	n := len(pcm) / v.format.BytesPerSample() / v.channels
+	hi,lo := bits.Mul64(uint64(n), 2*uint64(v.osr))
+	lo,carry := bits.Add64(lo, uint64(v.isr), 0)
+	nbSamples,_ := bits.Div64(hi+carry, lo, 2*uint64(v.isr))
+
+	// Extend the edges for the window of first and last output samples.
+	before,after := v.interp.support()
+	lookahead := resample_lookahead(after)
+
+	opcms := make([][]float64, v.channels)
+	for i := range opcms {
+		if n == 0 {
+			break
+		}
+
+		ipcm := resample_split_format(nil, pcm, v.format, v.channels, i)
+		ipcm = resample_reflect(ipcm, before, lookahead)
+
+		// The position of first output sample is 0, after the extended samples.
+		p := newPosition(v.isr, v.osr, 0)
+		p.pos += uint64(before)
+
+		var opcm []float64
+		if opcm,_,_,err = resample_channel(make([]float64, 0, nbSamples+1), ipcm, p, 0, v.interp); err != nil {
+			return nil,err
+		}
+		opcms[i] = opcm[:nbSamples]
+	}
+
+	npcm = make([]byte, int(nbSamples)*v.channels*v.nformat.BytesPerSample())
+	if n > 0 {
+		resample_merge_format(npcm, opcms, v.nformat, v.dither)
+	}
+	return npcm,nil
+}
+
+// Extend the channel by the odd reflection, the before samples at the start, and after at the end,
+// the reflection is clamped at the other end when the channel is shorter than extended.
+func resample_reflect(ipcm []float64, before, after int) []float64 {
+	n := len(ipcm)
+	clamp := func(k int) int {
+		if k < 0 {
+			return 0
+		}
+		if k > n-1 {
+			return n-1
+		}
+		return k
+	}
+
+	opcm := make([]float64, before+n+after)
+	for i:=0; i<before; i++ {
+		opcm[i] = 2*ipcm[0] - ipcm[clamp(before-i)]
+	}
+	copy(opcm[before:], ipcm)
+	for i:=0; i<after; i++ {
+		opcm[before+n+i] = 2*ipcm[n-1] - ipcm[clamp(n-2-i)]
+	}
+	return opcm
+}
@@ -0,0 +1,113 @@
+// The MIT License (MIT)
+//
+// Copyright (c) 2016 winlin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+
+// The PCM resample.
+package aresample
+
+import (
+	"math"
+	"math/rand"
+)
+
+// The type of dither, the noise added at the final quantization to integer.
+type DitherType int
+
+const (
+	// No dither, truncate the sample.
+	DitherNone DitherType = iota
+	// The rectangular dither, the uniform noise in [-0.5,0.5)LSB.
+	DitherRectangular
+	// The TPDF(triangular probability density function) dither, the noise in (-1,1)LSB,
+	// which removes the noise modulation of the signal.
+	DitherTriangular
+	// The TPDF dither with the Lipshitz noise shaping, which moves the noise to high
+	// frequency where the ear is less sensitive, for 44.1KHZ or 48KHZ.
+	DitherLipshitz
+)
+
+// The noise shaping filter of Lipshitz, see "Minimally audible noise shaping", 1991.
+var ditherLipshitz = []float64{2.033, -2.165, 1.959, -1.590, 0.6149}
+
+// The dither to quantize the samples, which keeps the noise shaping error of each channel,
+// so it must be used for one stream.
+type Dither struct {
+	dtype  DitherType
+	rng    *rand.Rand
+	coeffs []float64   // The noise shaping filter, nil for no shaping.
+	errs   [][]float64 // The history of quantization errors of each channel.
+}
+
+// Create the dither of type, the noise is generated from seed, so the output is
+// deterministic for the same seed and input.
+func NewDither(dtype DitherType, seed int64) (*Dither, error) {
+	if dtype < DitherNone || dtype > DitherLipshitz {
+		return nil,&ParamError{Name: "dither", Value: dtype, Err: ErrInvalidParameter}
+	}
+
+	v := &Dither{dtype: dtype, rng: rand.New(rand.NewSource(seed))}
+	if dtype == DitherLipshitz {
+		v.coeffs = ditherLipshitz
+	}
+	return v,nil
+}
+
+// The type of dither.
+func (v *Dither) Type() DitherType {
+	return v.dtype
+}
+
+// The noise of dither in LSB.
+func (v *Dither) noise() float64 {
+	switch v.dtype {
+	case DitherRectangular:
+		return v.rng.Float64() - 0.5
+	case DitherTriangular, DitherLipshitz:
+		return v.rng.Float64() - v.rng.Float64()
+	}
+	return 0
+}
+
+// Quantize the sample x in the scale of LSB for channel ch, return the integer value,
+// which is truncated when no dither, otherwise rounded with the noise.
+func (v *Dither) quantize(x float64, ch int) float64 {
+	if v.dtype == DitherNone {
+		return math.Trunc(x)
+	}
+
+	for len(v.errs) <= ch {
+		v.errs = append(v.errs, make([]float64, len(v.coeffs)))
+	}
+
+	// Feedback the filtered error, so the noise is shaped by 1-H(z).
+	e := v.errs[ch]
+	d := x
+	for i,h := range v.coeffs {
+		d -= h * e[i]
+	}
+
+	q := math.Floor(d + v.noise() + 0.5)
+	if len(e) > 0 {
+		copy(e[1:], e[:len(e)-1])
+		e[0] = q - d
+	}
+	return q
+}
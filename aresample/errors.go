@@ -0,0 +1,102 @@
+// The MIT License (MIT)
+//
+// Copyright (c) 2016 winlin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+
+// The PCM resample.
+package aresample
+
+import (
+	"errors"
+	"fmt"
+)
+
+// The errors of validation, use errors.Is to check the error, and errors.As
+// to get the detail, for example, the *UnalignedError for ErrUnaligned.
+var (
+	ErrInvalidChannels   = errors.New("invalid channels")
+	ErrInvalidSampleRate = errors.New("invalid sample rate")
+	ErrInvalidFormat     = errors.New("invalid sample format")
+	ErrInvalidParameter  = errors.New("invalid parameter")
+	ErrUnaligned         = errors.New("unaligned size")
+	ErrTooShort          = errors.New("too short")
+	ErrBufferSize        = errors.New("invalid buffer size")
+	ErrClosed            = errors.New("closed")
+)
+
+// The invalid parameter, for example, the channels, sample rate or taps.
+type ParamError struct {
+	Name  string      // The name of parameter.
+	Value interface{} // The invalid value.
+	Err   error       // The kind of error, for example, ErrInvalidChannels.
+}
+
+func (v *ParamError) Error() string {
+	return fmt.Sprintf("invalid %v=%v", v.Name, v.Value)
+}
+
+func (v *ParamError) Unwrap() error {
+	return v.Err
+}
+
+// The size is not aligned, for example, the pcm is not whole frames.
+type UnalignedError struct {
+	Name  string // The name of buffer, for example, pcm.
+	Size  int    // The size of buffer.
+	Align int    // The size should be multiple of it.
+}
+
+func (v *UnalignedError) Error() string {
+	return fmt.Sprintf("invalid %v size=%v, should mod(%v)", v.Name, v.Size, v.Align)
+}
+
+func (v *UnalignedError) Unwrap() error {
+	return ErrUnaligned
+}
+
+// The buffer is shorter than required.
+type TooShortError struct {
+	Name string // The name of buffer.
+	Size int    // The size of buffer.
+	Min  int    // The min size required.
+}
+
+func (v *TooShortError) Error() string {
+	return fmt.Sprintf("invalid %v size=%v, atleast %v", v.Name, v.Size, v.Min)
+}
+
+func (v *TooShortError) Unwrap() error {
+	return ErrTooShort
+}
+
+// The size of buffer is not expected, for example, the npcm is not large enough.
+type BufferSizeError struct {
+	Name   string // The name of buffer, for example, npcm.
+	Size   int    // The size of buffer.
+	Expect int    // The expected size, or the min size if buffer is too small.
+}
+
+func (v *BufferSizeError) Error() string {
+	return fmt.Sprintf("invalid %v size=%v, should be %v", v.Name, v.Size, v.Expect)
+}
+
+func (v *BufferSizeError) Unwrap() error {
+	return ErrBufferSize
+}
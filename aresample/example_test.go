@@ -94,9 +94,17 @@ func ExamplePcmS16leResampler() {
 	fmt.Println("16KHZ PCM:", len(pcm))
 	fmt.Println("32KHZ NPCM:", len(npcm))
 
+	// Flush the cached samples at the end of stream.
+	if npcm,err = r.Flush(); err != nil {
+		fmt.Println("aresample failed, err is", err)
+		return
+	}
+	fmt.Println("32KHZ Flush:", len(npcm))
+
 	// Output:
 	// 16KHZ PCM: 106
 	// 32KHZ NPCM: 148
 	// 16KHZ PCM: 106
 	// 32KHZ NPCM: 212
+	// 32KHZ Flush: 64
 }
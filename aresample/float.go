@@ -0,0 +1,232 @@
+// The MIT License (MIT)
+//
+// Copyright (c) 2016 winlin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+
+// The PCM resample.
+package aresample
+
+import "fmt"
+
+// The scale of float samples in [-1,1] to the int16 samples.
+const s16Scale = 32768.0
+
+// The resampler for float samples, which is normalized in [-1,1],
+// resampled by the same kernels without converting to int16,
+// and shares the streaming cache with ResampleSampleRate.
+type ResampleFloat interface {
+	// Resample the interleaved samples, where len(pcm) must align to channels.
+	ResampleFloat32(pcm []float32) (npcm []float32, err error)
+	ResampleFloat64(pcm []float64) (npcm []float64, err error)
+	// Resample the planar samples, where pcm[i] is the samples of channel i.
+	ResamplePlanarFloat32(pcm [][]float32) (npcm [][]float32, err error)
+	ResamplePlanarFloat64(pcm [][]float64) (npcm [][]float64, err error)
+	// Flush the cached samples, in interleaved or planar samples.
+	FlushFloat32() (npcm []float32, err error)
+	FlushFloat64() (npcm []float64, err error)
+	FlushPlanarFloat32() (npcm [][]float32, err error)
+	FlushPlanarFloat64() (npcm [][]float64, err error)
+}
+
+func (v *srResampler) ResampleFloat32(pcm []float32) (npcm []float32, err error) {
+	if (len(pcm)%v.channels) != 0 {
+		return nil,&UnalignedError{Name: "pcm", Size: len(pcm), Align: v.channels}
+	}
+
+	bypass,err := v.resample_float(func() {
+		for i := range pcm {
+			c := v.chs[i%v.channels]
+			c.cache = append(c.cache, float64(pcm[i])*s16Scale)
+		}
+	})
+	if err != nil {
+		return nil,err
+	}
+	if bypass {
+		return pcm,nil
+	}
+
+	return v.interleave_float32(),nil
+}
+
+func (v *srResampler) ResampleFloat64(pcm []float64) (npcm []float64, err error) {
+	if (len(pcm)%v.channels) != 0 {
+		return nil,&UnalignedError{Name: "pcm", Size: len(pcm), Align: v.channels}
+	}
+
+	bypass,err := v.resample_float(func() {
+		for i := range pcm {
+			c := v.chs[i%v.channels]
+			c.cache = append(c.cache, pcm[i]*s16Scale)
+		}
+	})
+	if err != nil {
+		return nil,err
+	}
+	if bypass {
+		return pcm,nil
+	}
+
+	return v.interleave_float64(),nil
+}
+
+func (v *srResampler) ResamplePlanarFloat32(pcm [][]float32) (npcm [][]float32, err error) {
+	if err = v.validate_planar(len(pcm), func(i int) int { return len(pcm[i]) }); err != nil {
+		return nil,err
+	}
+
+	bypass,err := v.resample_float(func() {
+		for i,c := range v.chs {
+			for _,s := range pcm[i] {
+				c.cache = append(c.cache, float64(s)*s16Scale)
+			}
+		}
+	})
+	if err != nil {
+		return nil,err
+	}
+	if bypass {
+		return pcm,nil
+	}
+
+	return v.planar_float32(),nil
+}
+
+func (v *srResampler) ResamplePlanarFloat64(pcm [][]float64) (npcm [][]float64, err error) {
+	if err = v.validate_planar(len(pcm), func(i int) int { return len(pcm[i]) }); err != nil {
+		return nil,err
+	}
+
+	bypass,err := v.resample_float(func() {
+		for i,c := range v.chs {
+			for _,s := range pcm[i] {
+				c.cache = append(c.cache, s*s16Scale)
+			}
+		}
+	})
+	if err != nil {
+		return nil,err
+	}
+	if bypass {
+		return pcm,nil
+	}
+
+	return v.planar_float64(),nil
+}
+
+// Resample the float samples, where fill appends the samples to the cache of each channel
+// in the scale of int16, return bypass to use the input samples as output.
+func (v *srResampler) resample_float(fill func()) (bypass bool, err error) {
+	// Bypass when no cached samples of previous rate.
+	if v.bypass() {
+		return true,nil
+	}
+
+	fill()
+	return false,v.resample_cache()
+}
+
+func (v *srResampler) FlushFloat32() (npcm []float32, err error) {
+	if err = v.flush_cache(); err != nil {
+		return nil,err
+	}
+	return v.interleave_float32(),nil
+}
+
+func (v *srResampler) FlushFloat64() (npcm []float64, err error) {
+	if err = v.flush_cache(); err != nil {
+		return nil,err
+	}
+	return v.interleave_float64(),nil
+}
+
+func (v *srResampler) FlushPlanarFloat32() (npcm [][]float32, err error) {
+	if err = v.flush_cache(); err != nil {
+		return nil,err
+	}
+	return v.planar_float32(),nil
+}
+
+func (v *srResampler) FlushPlanarFloat64() (npcm [][]float64, err error) {
+	if err = v.flush_cache(); err != nil {
+		return nil,err
+	}
+	return v.planar_float64(),nil
+}
+
+// Validate the planar pcm, which contains nbChannels, each channel contains size(i) samples.
+func (v *srResampler) validate_planar(nbChannels int, size func(i int) int) error {
+	if nbChannels != v.channels {
+		return &BufferSizeError{Name: "planar", Size: nbChannels, Expect: v.channels}
+	}
+	for i:=1; i<nbChannels; i++ {
+		if size(i) != size(0) {
+			return &BufferSizeError{Name: fmt.Sprintf("planar[%v]", i), Size: size(i), Expect: size(0)}
+		}
+	}
+
+	return nil
+}
+
+// Interleave the output opcms to float32.
+func (v *srResampler) interleave_float32() (npcm []float32) {
+	npcm = make([]float32, len(v.opcms[0])*v.channels)
+	for ch,opcm := range v.opcms {
+		for i := range opcm {
+			npcm[i*v.channels+ch] = float32(opcm[i]/s16Scale)
+		}
+	}
+	return
+}
+
+// Interleave the output opcms to float64.
+func (v *srResampler) interleave_float64() (npcm []float64) {
+	npcm = make([]float64, len(v.opcms[0])*v.channels)
+	for ch,opcm := range v.opcms {
+		for i := range opcm {
+			npcm[i*v.channels+ch] = opcm[i]/s16Scale
+		}
+	}
+	return
+}
+
+// Copy the output opcms to planar float32.
+func (v *srResampler) planar_float32() (npcm [][]float32) {
+	for _,opcm := range v.opcms {
+		c := make([]float32, len(opcm))
+		for i,s := range opcm {
+			c[i] = float32(s/s16Scale)
+		}
+		npcm = append(npcm, c)
+	}
+	return
+}
+
+// Copy the output opcms to planar float64.
+func (v *srResampler) planar_float64() (npcm [][]float64) {
+	for _,opcm := range v.opcms {
+		c := make([]float64, len(opcm))
+		for i,s := range opcm {
+			c[i] = s/s16Scale
+		}
+		npcm = append(npcm, c)
+	}
+	return
+}
@@ -0,0 +1,249 @@
+// The MIT License (MIT)
+//
+// Copyright (c) 2016 winlin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+
+// The PCM resample.
+package aresample
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// The format of PCM sample, for example, s16le, s24le or f32le.
+type SampleFormat struct {
+	Bits      int  // The bits of sample, 8, 16, 24 or 32 for integer, 32 or 64 for float.
+	Signed    bool // Whether signed integer, otherwise offset binary, ignored for float.
+	Float     bool // Whether IEEE float, normalized in [-1,1].
+	BigEndian bool // Whether big-endian, otherwise little-endian.
+	Packed    bool // Whether 24bits is packed in 3bytes, otherwise in the low 3bytes of 4bytes.
+}
+
+// The common sample formats.
+var (
+	FormatU8        = SampleFormat{Bits: 8}
+	FormatS8        = SampleFormat{Bits: 8, Signed: true}
+	FormatS16LE     = SampleFormat{Bits: 16, Signed: true}
+	FormatS16BE     = SampleFormat{Bits: 16, Signed: true, BigEndian: true}
+	FormatU16LE     = SampleFormat{Bits: 16}
+	FormatU16BE     = SampleFormat{Bits: 16, BigEndian: true}
+	FormatS24LE     = SampleFormat{Bits: 24, Signed: true, Packed: true}
+	FormatS24BE     = SampleFormat{Bits: 24, Signed: true, BigEndian: true, Packed: true}
+	FormatS24In32LE = SampleFormat{Bits: 24, Signed: true}
+	FormatS24In32BE = SampleFormat{Bits: 24, Signed: true, BigEndian: true}
+	FormatS32LE     = SampleFormat{Bits: 32, Signed: true}
+	FormatS32BE     = SampleFormat{Bits: 32, Signed: true, BigEndian: true}
+	FormatF32LE     = SampleFormat{Bits: 32, Float: true}
+	FormatF32BE     = SampleFormat{Bits: 32, Float: true, BigEndian: true}
+	FormatF64LE     = SampleFormat{Bits: 64, Float: true}
+	FormatF64BE     = SampleFormat{Bits: 64, Float: true, BigEndian: true}
+)
+
+// Validate the sample format.
+func (v SampleFormat) Validate() error {
+	if v.Float {
+		if v.Bits != 32 && v.Bits != 64 {
+			return &ParamError{Name: "float bits", Value: v.Bits, Err: ErrInvalidFormat}
+		}
+		return nil
+	}
+
+	if v.Bits != 8 && v.Bits != 16 && v.Bits != 24 && v.Bits != 32 {
+		return &ParamError{Name: "bits", Value: v.Bits, Err: ErrInvalidFormat}
+	}
+	return nil
+}
+
+// The bytes of each sample, for example, 2 for s16le, 3 for packed s24le.
+func (v SampleFormat) BytesPerSample() int {
+	if v.Bits == 24 && !v.Packed {
+		return 4
+	}
+	return v.Bits / 8
+}
+
+func (v SampleFormat) String() string {
+	if err := v.Validate(); err != nil {
+		return fmt.Sprintf("invalid(%v)", v.Bits)
+	}
+
+	name := fmt.Sprintf("s%v", v.Bits)
+	if v.Float {
+		name = fmt.Sprintf("f%v", v.Bits)
+	} else if !v.Signed {
+		name = fmt.Sprintf("u%v", v.Bits)
+	}
+	if v.Bits == 24 && !v.Packed {
+		name += "in32"
+	}
+
+	if v.Bits == 8 {
+		return name
+	} else if v.BigEndian {
+		return name + "be"
+	}
+	return name + "le"
+}
+
+// Decode the sample b, normalized in [-1,1).
+func (v SampleFormat) decode(b []byte) float64 {
+	var order binary.ByteOrder = binary.LittleEndian
+	if v.BigEndian {
+		order = binary.BigEndian
+	}
+
+	if v.Float {
+		if v.Bits == 32 {
+			return float64(math.Float32frombits(order.Uint32(b)))
+		}
+		return math.Float64frombits(order.Uint64(b))
+	}
+
+	// Read the raw bits of sample.
+	var u uint64
+	switch v.BytesPerSample() {
+	case 1:
+		u = uint64(b[0])
+	case 2:
+		u = uint64(order.Uint16(b))
+	case 3:
+		if v.BigEndian {
+			u = uint64(b[0])<<16 | uint64(b[1])<<8 | uint64(b[2])
+		} else {
+			u = uint64(b[2])<<16 | uint64(b[1])<<8 | uint64(b[0])
+		}
+	case 4:
+		u = uint64(order.Uint32(b)) & (1<<uint(v.Bits) - 1)
+	}
+
+	// Sign extend, or remove the offset of unsigned.
+	var x int64
+	if v.Signed {
+		x = int64(u<<uint(64-v.Bits)) >> uint(64-v.Bits)
+	} else {
+		x = int64(u) - 1<<uint(v.Bits-1)
+	}
+
+	return float64(x) / float64(int64(1)<<uint(v.Bits-1))
+}
+
+// Encode the sample f in [-1,1) to b, truncate the integer and saturate to the range,
+// return whether clipped.
+func (v SampleFormat) encode(b []byte, f float64) (clipped bool) {
+	return v.encode_dither(b, f, nil, 0)
+}
+
+// Encode the sample f of channel ch like encode, but quantize the integer by dither if not nil.
+func (v SampleFormat) encode_dither(b []byte, f float64, d *Dither, ch int) (clipped bool) {
+	var order binary.ByteOrder = binary.LittleEndian
+	if v.BigEndian {
+		order = binary.BigEndian
+	}
+
+	if v.Float {
+		if v.Bits == 32 {
+			order.PutUint32(b, math.Float32bits(float32(f)))
+		} else {
+			order.PutUint64(b, math.Float64bits(f))
+		}
+		return false
+	}
+
+	// Saturate to the range of integer.
+	scale := float64(int64(1) << uint(v.Bits-1))
+	x := f * scale
+	if d != nil {
+		x = d.quantize(x, ch)
+	}
+	if x >= scale {
+		x,clipped = scale-1,true
+	} else if x <= -scale-1 {
+		x,clipped = -scale,true
+	}
+
+	u := uint64(int64(x))
+	if !v.Signed {
+		u = uint64(int64(x) + int64(scale))
+	}
+
+	switch v.BytesPerSample() {
+	case 1:
+		b[0] = byte(u)
+	case 2:
+		order.PutUint16(b, uint16(u))
+	case 3:
+		if v.BigEndian {
+			b[0],b[1],b[2] = byte(u>>16),byte(u>>8),byte(u)
+		} else {
+			b[0],b[1],b[2] = byte(u),byte(u>>8),byte(u>>16)
+		}
+	case 4:
+		order.PutUint32(b, uint32(u) & (1<<uint(v.Bits) - 1))
+	}
+	return
+}
+
+// Convert the pcm from format to nformat, for example, from s24le to s16be.
+// @remark the float is normalized in [-1,1], and the integer is truncated.
+func ConvertSampleFormat(pcm []byte, format, nformat SampleFormat) (npcm []byte, err error) {
+	if err = format.Validate(); err != nil {
+		return nil,err
+	}
+	if err = nformat.Validate(); err != nil {
+		return nil,err
+	}
+
+	size := format.BytesPerSample()
+	if (len(pcm)%size) != 0 {
+		return nil,&UnalignedError{Name: "pcm", Size: len(pcm), Align: size}
+	}
+
+	npcm = make([]byte, len(pcm)/size*nformat.BytesPerSample())
+	resample_convert(npcm, pcm, format, nformat, 1, nil)
+
+	return
+}
+
+// Convert the pcm of channels from format to nformat like ConvertSampleFormat,
+// and quantize to the integer nformat by the dither, which should be reused for
+// the pcm of the same stream to keep the noise shaping continuous.
+func ConvertSampleFormatDither(pcm []byte, format, nformat SampleFormat, channels int, dither *Dither) (npcm []byte, err error) {
+	if err = format.Validate(); err != nil {
+		return nil,err
+	}
+	if err = nformat.Validate(); err != nil {
+		return nil,err
+	}
+	if channels < 1 || channels > maxChannels {
+		return nil,&ParamError{Name: "channels", Value: channels, Err: ErrInvalidChannels}
+	}
+
+	size := format.BytesPerSample()
+	if (len(pcm)%(size*channels)) != 0 {
+		return nil,&UnalignedError{Name: "pcm", Size: len(pcm), Align: size*channels}
+	}
+
+	npcm = make([]byte, len(pcm)/size*nformat.BytesPerSample())
+	resample_convert(npcm, pcm, format, nformat, channels, dither)
+
+	return
+}
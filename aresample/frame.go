@@ -0,0 +1,163 @@
+// The MIT License (MIT)
+//
+// Copyright (c) 2016 winlin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+
+// The PCM resample.
+package aresample
+
+import "math"
+
+// The timebase of timestamp, the pts*Num/Den is in seconds, for example, 1/1000 for ms.
+type Timebase struct {
+	Num int64
+	Den int64
+}
+
+// Convert the pts in timebase to seconds.
+func (v Timebase) seconds(pts int64) float64 {
+	return float64(pts) * float64(v.Num) / float64(v.Den)
+}
+
+// Convert the seconds to the pts in timebase, rounded to nearest.
+func (v Timebase) pts(seconds float64) int64 {
+	return int64(math.Floor(seconds*float64(v.Den)/float64(v.Num) + 0.5))
+}
+
+// The frame of output pcm, the PTS in Timebase is the timestamp of first sample.
+type Frame struct {
+	PCM      []byte
+	PTS      int64
+	Timebase Timebase
+}
+
+// The timestamp of input frame, which is the ground truth to map the pts of output.
+type srAnchor struct {
+	index uint64   // The position of first sample of frame, in input samples.
+	pts   int64    // The pts of first sample of frame.
+	tb    Timebase // The timebase of pts.
+	isr   int      // The sample rate of frame.
+}
+
+// The pts in seconds of the position in input samples, from the anchor.
+func (v *srAnchor) seconds(p srPosition) float64 {
+	x := float64(p.pos) - float64(v.index) + float64(p.frac)/float64(p.den)
+	return v.tb.seconds(v.pts) + x/float64(v.isr)
+}
+
+// Resample the pcm, which starts at pts in tb, to frames with the mapped pts, that is the pts
+// of the first input sample plus the time of its position in input samples, where the position
+// is from the consumed and written samples, so it never drift by counting bytes. When the input
+// timestamps have a gap, the output is split at the gap, so each frame has the correct pts.
+func (v *srResampler) ResampleFrame(pcm []byte, pts int64, tb Timebase) (frames []Frame, err error) {
+	if tb.Num <= 0 || tb.Den <= 0 {
+		return nil,&ParamError{Name: "timebase", Value: tb, Err: ErrInvalidParameter}
+	}
+	if err = v.validate(pcm); err != nil {
+		return nil,err
+	}
+
+	// Bypass the pcm and the pts.
+	if v.bypass() {
+		var npcm []byte
+		if npcm,err = v.Resample(pcm); err != nil {
+			return nil,err
+		}
+		return []Frame{{PCM: npcm, PTS: pts, Timebase: tb}},nil
+	}
+
+	c := v.chs[0]
+	v.anchors = append(v.anchors, srAnchor{index: c.cs + uint64(len(c.cache)), pts: pts, tb: tb, isr: v.isr})
+
+	p,switching := c.pos,v.sw != nil
+	var npcm []byte
+	if npcm,err = v.Resample(pcm); err != nil {
+		return nil,err
+	}
+
+	return v.frames(npcm, p, switching, tb),nil
+}
+
+func (v *srResampler) FlushFrame() (frames []Frame, err error) {
+	tb := Timebase{1, int64(v.osr)}
+	if len(v.anchors) > 0 {
+		tb = v.anchors[len(v.anchors)-1].tb
+	}
+
+	p,switching := v.chs[0].pos,v.sw != nil
+	var npcm []byte
+	if npcm,err = v.Flush(); err != nil {
+		return nil,err
+	}
+
+	return v.frames(npcm, p, switching, tb),nil
+}
+
+// Split the npcm, which starts at position p, to frames at the gaps of anchors.
+func (v *srResampler) frames(npcm []byte, p srPosition, switching bool, tb Timebase) (frames []Frame) {
+	nframe := v.nformat.BytesPerSample()*v.channels
+	n := len(npcm) / nframe
+
+	// Split at the gap, the position of samples is unknown when switching rate.
+	start := 0
+	for i:=1; i<len(v.anchors) && !switching; i++ {
+		if !v.gap(i) {
+			continue
+		}
+		if k := int(p.before(v.anchors[i].index)); k > start && k < n {
+			frames = append(frames, Frame{PCM: npcm[start*nframe:k*nframe], PTS: v.pts(p.advance(uint64(start)), tb), Timebase: tb})
+			start = k
+		}
+	}
+	if start < n {
+		frames = append(frames, Frame{PCM: npcm[start*nframe:], PTS: v.pts(p.advance(uint64(start)), tb), Timebase: tb})
+	}
+
+	// Remove the anchors before the position of next output sample.
+	pos := v.chs[0].pos.pos
+	for len(v.anchors) > 1 && v.anchors[1].index <= pos {
+		v.anchors = v.anchors[:copy(v.anchors, v.anchors[1:])]
+	}
+
+	return
+}
+
+// Whether there is a gap between the anchor i and i-1, that is, the pts of anchor i
+// is not continuous to i-1 by more than half sample, with the error of timebase.
+func (v *srResampler) gap(i int) bool {
+	a,b := &v.anchors[i],&v.anchors[i-1]
+	expect := b.tb.seconds(b.pts) + float64(a.index-b.index)/float64(b.isr)
+	return math.Abs(a.tb.seconds(a.pts) - expect) > 0.5/float64(b.isr) + a.tb.seconds(1)
+}
+
+// The pts in tb of the position, from the last anchor before it.
+func (v *srResampler) pts(p srPosition, tb Timebase) int64 {
+	if len(v.anchors) == 0 {
+		return tb.pts(float64(p.pos)/float64(v.isr))
+	}
+
+	a := &v.anchors[0]
+	for i := range v.anchors {
+		if v.anchors[i].index <= p.pos {
+			a = &v.anchors[i]
+		}
+	}
+	return tb.pts(a.seconds(p))
+}
@@ -0,0 +1,203 @@
+// The MIT License (MIT)
+//
+// Copyright (c) 2016 winlin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+
+// The PCM resample.
+package aresample
+
+import "math"
+
+// The kernel to interpolate the sample at fractional position between input samples,
+// to trade CPU vs quality, for example, KernelLinear for voice and KernelSinc for music.
+type Kernel interface {
+	// The number of samples required before and after the position x,
+	// that is, the window is [floor(x)-before, floor(x)+after].
+	Taps() (before, after int)
+	// Interpolate the window w at position floor(x)+frac, where frac is in [0,1),
+	// that is, between w[before] and w[before+1].
+	Interpolate(w []float64, frac float64) (float64, error)
+}
+
+// Create the kernel to resample from isr to osr, which is called again by Reconfigure,
+// because the anti-alias filter depends on the rates, see Config.
+type KernelFunc func(isr, osr int) (Kernel, error)
+
+// The nearest neighbour, which is fastest but aliasing and noisy, for test or non-audio signal.
+func KernelNearest(isr, osr int) (Kernel, error) {
+	return &nearestKernel{},nil
+}
+
+// The 2-points linear interpolation, which is fast but attenuates high frequencies and aliasing.
+func KernelLinear(isr, osr int) (Kernel, error) {
+	return &linearKernel{},nil
+}
+
+// The 4-points cubic Hermite(Catmull-Rom) interpolation, which is local and passes through samples.
+func KernelCubic(isr, osr int) (Kernel, error) {
+	return &cubicKernel{},nil
+}
+
+// The 4-points natural cubic spline, which is the default kernel, compatible with FFMPEG.
+func KernelSpline(isr, osr int) (Kernel, error) {
+	return &splineInterpolator{},nil
+}
+
+// The Lanczos kernel sinc(t)*sinc(t/a) of a lobes, for example, 2 or 3,
+// which is stretched by isr/osr to filter the alias when downsampling.
+func KernelLanczos(a int) KernelFunc {
+	return func(isr, osr int) (Kernel, error) {
+		return newLanczosKernel(isr, osr, a)
+	}
+}
+
+// The windowed-sinc(Kaiser) band-limited kernel, see NewPcmS16leSincResampler.
+func KernelSinc(taps int, cutoff float64) KernelFunc {
+	return func(isr, osr int) (Kernel, error) {
+		return newSincInterpolator(isr, osr, taps, cutoff)
+	}
+}
+
+// The polyphase filter bank of windowed-sinc kernel, see NewPcmS16lePolyphaseResampler.
+func KernelPolyphase(taps int, cutoff float64) KernelFunc {
+	return func(isr, osr int) (Kernel, error) {
+		return newPolyphaseBank(isr, osr, taps, cutoff)
+	}
+}
+
+// Create the interpolator of kernel, use the exact fraction if supported, for example, the polyphase.
+func newKernelInterpolator(kernel KernelFunc, isr, osr int) (interpolator, error) {
+	k,err := kernel(isr, osr)
+	if err != nil {
+		return nil,err
+	}
+	if k == nil {
+		return nil,&ParamError{Name: "kernel", Value: k, Err: ErrInvalidParameter}
+	}
+	if before,after := k.Taps(); before < 0 || after < 1 {
+		return nil,&ParamError{Name: "taps", Value: [2]int{before, after}, Err: ErrInvalidParameter}
+	}
+
+	if v,ok := k.(interpolator); ok {
+		return v,nil
+	}
+	return &kernelInterpolator{k: k},nil
+}
+
+// The interpolator of user kernel.
+type kernelInterpolator struct {
+	k Kernel
+}
+
+func (v *kernelInterpolator) support() (before, after int) {
+	return v.k.Taps()
+}
+
+func (v *kernelInterpolator) interpolate(w []float64, frac, den uint64) (float64, error) {
+	return v.k.Interpolate(w, float64(frac)/float64(den))
+}
+
+// The nearest neighbour kernel.
+type nearestKernel struct {
+}
+
+func (v *nearestKernel) Taps() (before, after int) {
+	return 0,1
+}
+
+func (v *nearestKernel) Interpolate(w []float64, frac float64) (float64, error) {
+	if frac < 0.5 {
+		return w[0],nil
+	}
+	return w[1],nil
+}
+
+// The linear kernel.
+type linearKernel struct {
+}
+
+func (v *linearKernel) Taps() (before, after int) {
+	return 0,1
+}
+
+func (v *linearKernel) Interpolate(w []float64, frac float64) (float64, error) {
+	return w[0] + frac*(w[1]-w[0]),nil
+}
+
+// The cubic Hermite kernel, the tangent is (y[k+1]-y[k-1])/2, that is Catmull-Rom.
+type cubicKernel struct {
+}
+
+func (v *cubicKernel) Taps() (before, after int) {
+	return 1,2
+}
+
+func (v *cubicKernel) Interpolate(w []float64, frac float64) (float64, error) {
+	y0,y1,y2,y3 := w[0],w[1],w[2],w[3]
+	c1 := (y2 - y0) / 2
+	c2 := y0 - 2.5*y1 + 2*y2 - y3/2
+	c3 := (y3 - y0) / 2 + 1.5*(y1 - y2)
+	return y1 + frac*(c1 + frac*(c2 + frac*c3)),nil
+}
+
+// The Lanczos kernel, normalized to unity gain at DC.
+type lanczosKernel struct {
+	a     float64 // The lobes of kernel.
+	scale float64 // The osr/isr when downsampling, or 1.
+	hw    float64 // The half width of kernel, in input samples.
+
+	before int // The samples before the position.
+	after  int // The samples after the position.
+}
+
+func newLanczosKernel(isr, osr int, a int) (*lanczosKernel, error) {
+	if a < 1 || a > 64 {
+		return nil,&ParamError{Name: "lobes", Value: a, Err: ErrInvalidParameter}
+	}
+
+	scale := 1.0
+	if osr < isr {
+		scale = float64(osr) / float64(isr)
+	}
+
+	v := &lanczosKernel{a: float64(a), scale: scale, hw: float64(a) / scale}
+	v.before = int(math.Ceil(v.hw)) - 1
+	v.after = int(math.Ceil(v.hw))
+	return v,nil
+}
+
+func (v *lanczosKernel) Taps() (before, after int) {
+	return v.before,v.after
+}
+
+func (v *lanczosKernel) Interpolate(w []float64, frac float64) (float64, error) {
+	var y, sum float64
+	for k, s := range w {
+		// The distance from sample w[k] to position, in output samples when downsampling.
+		t := (frac - float64(k-v.before)) * v.scale
+		if t <= -v.a || t >= v.a {
+			continue
+		}
+		h := sinc(t) * sinc(t/v.a)
+		y += s * h
+		sum += h
+	}
+	return y / sum,nil
+}
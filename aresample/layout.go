@@ -0,0 +1,150 @@
+// The MIT License (MIT)
+//
+// Copyright (c) 2016 winlin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+
+// The PCM resample.
+package aresample
+
+import (
+	"fmt"
+	"math/bits"
+	"strings"
+)
+
+// The speaker position of channel, the same bit as the channel mask of WAVE_FORMAT_EXTENSIBLE.
+type Speaker uint32
+
+const (
+	SpeakerFrontLeft          Speaker = 1 << iota // FL
+	SpeakerFrontRight                             // FR
+	SpeakerFrontCenter                            // FC
+	SpeakerLowFrequency                           // LFE
+	SpeakerBackLeft                               // BL
+	SpeakerBackRight                              // BR
+	SpeakerFrontLeftOfCenter                      // FLC
+	SpeakerFrontRightOfCenter                     // FRC
+	SpeakerBackCenter                             // BC
+	SpeakerSideLeft                               // SL
+	SpeakerSideRight                              // SR
+)
+
+// The max speakers of layout.
+const maxSpeakers = 11
+
+func (v Speaker) String() string {
+	names := []string{"FL", "FR", "FC", "LFE", "BL", "BR", "FLC", "FRC", "BC", "SL", "SR"}
+	if bits.OnesCount32(uint32(v)) == 1 && v < 1<<maxSpeakers {
+		return names[bits.TrailingZeros32(uint32(v))]
+	}
+	return fmt.Sprintf("unknown(%#x)", uint32(v))
+}
+
+// The channel layout, the set of speakers, where the channels are in the order of speaker bits,
+// for example, the 5.1 is FL,FR,FC,LFE,BL,BR, which is the order of WAV.
+type ChannelLayout uint32
+
+// The common channel layouts.
+const (
+	LayoutMono    = ChannelLayout(SpeakerFrontCenter)
+	LayoutStereo  = ChannelLayout(SpeakerFrontLeft | SpeakerFrontRight)
+	Layout2Point1 = LayoutStereo | ChannelLayout(SpeakerLowFrequency)
+	LayoutQuad    = LayoutStereo | ChannelLayout(SpeakerBackLeft | SpeakerBackRight)
+	Layout5Point1 = LayoutQuad | ChannelLayout(SpeakerFrontCenter | SpeakerLowFrequency)
+	Layout7Point1 = Layout5Point1 | ChannelLayout(SpeakerSideLeft | SpeakerSideRight)
+)
+
+// The default layout of channels, 0 if no default layout.
+func LayoutOf(channels int) ChannelLayout {
+	switch channels {
+	case 1:
+		return LayoutMono
+	case 2:
+		return LayoutStereo
+	case 3:
+		return Layout2Point1
+	case 4:
+		return LayoutQuad
+	case 6:
+		return Layout5Point1
+	case 8:
+		return Layout7Point1
+	}
+	return 0
+}
+
+// Validate the channel layout.
+func (v ChannelLayout) Validate() error {
+	if v == 0 || v >= 1<<maxSpeakers {
+		return &ParamError{Name: "layout", Value: fmt.Sprintf("%#x", uint32(v)), Err: ErrInvalidChannels}
+	}
+	return nil
+}
+
+// The number of channels.
+func (v ChannelLayout) Channels() int {
+	return bits.OnesCount32(uint32(v))
+}
+
+// The speakers in the order of channels.
+func (v ChannelLayout) Speakers() (speakers []Speaker) {
+	for s := Speaker(1); s < 1<<maxSpeakers; s <<= 1 {
+		if v.Has(s) {
+			speakers = append(speakers, s)
+		}
+	}
+	return
+}
+
+// Whether the layout contains the speaker.
+func (v ChannelLayout) Has(s Speaker) bool {
+	return uint32(v)&uint32(s) != 0
+}
+
+// The channel index of speaker, -1 if not found.
+func (v ChannelLayout) Index(s Speaker) int {
+	if !v.Has(s) {
+		return -1
+	}
+	return bits.OnesCount32(uint32(v) & (uint32(s) - 1))
+}
+
+func (v ChannelLayout) String() string {
+	switch v {
+	case LayoutMono:
+		return "mono"
+	case LayoutStereo:
+		return "stereo"
+	case Layout2Point1:
+		return "2.1"
+	case LayoutQuad:
+		return "quad"
+	case Layout5Point1:
+		return "5.1"
+	case Layout7Point1:
+		return "7.1"
+	}
+
+	var names []string
+	for _,s := range v.Speakers() {
+		names = append(names, s.String())
+	}
+	return strings.Join(names, "+")
+}
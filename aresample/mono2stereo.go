@@ -22,19 +22,95 @@
 // The PCM resample.
 package aresample
 
-import "fmt"
+import "math"
 
-// Transform the mono pcm to stereo npcm, where len(npcm)===2*len(pcm).
+// The pan law, the attenuation of each channel when pan the mono to the center.
+type PanLaw int
+
+const (
+	// The -3dB equal-power law, the loudness is constant when pan from left to right.
+	PanLaw3dB PanLaw = iota
+	// The 0dB law, duplicate the mono to both channels at the center.
+	PanLaw0dB
+	// The -4.5dB compromise law, between the -3dB and -6dB.
+	PanLaw4_5dB
+	// The -6dB linear law, the amplitude is constant when pan from left to right.
+	PanLaw6dB
+)
+
+// The gain of PcmS16leMono2Stereo at the center, which is about cos(pi/4),
+// keep it to output the same samples as before.
+const mono2stereoGain = 0.7071
+
+// The options to transform mono to stereo.
+type Mono2StereoOptions struct {
+	// The pan position in [-1,1], -1 is left, 0 is center and 1 is right.
+	Pan float64
+	// The pan law, the default is -3dB.
+	Law PanLaw
+	// The extra gain in dB, 0 is no gain.
+	Gain float64
+}
+
+// The gain of L and R for the options.
+func (v *Mono2StereoOptions) gains() (l, r float64, err error) {
+	if math.IsNaN(v.Pan) || v.Pan < -1 || v.Pan > 1 {
+		return 0,0,&ParamError{Name: "pan", Value: v.Pan, Err: ErrInvalidParameter}
+	}
+	if math.IsNaN(v.Gain) || math.IsInf(v.Gain, 0) {
+		return 0,0,&ParamError{Name: "gain", Value: v.Gain, Err: ErrInvalidParameter}
+	}
+
+	// The linear gain and the equal-power gain of L and R.
+	ll,lr := (1 - v.Pan) / 2,(1 + v.Pan) / 2
+	theta := (v.Pan + 1) * math.Pi / 4
+	pl,pr := math.Cos(theta),math.Sin(theta)
+
+	switch v.Law {
+	case PanLaw3dB:
+		l,r = pl,pr
+		if v.Pan == 0 {
+			l,r = mono2stereoGain,mono2stereoGain
+		}
+	case PanLaw0dB:
+		l,r = math.Min(1, 2*ll),math.Min(1, 2*lr)
+	case PanLaw4_5dB:
+		l,r = math.Sqrt(ll*pl),math.Sqrt(lr*pr)
+	case PanLaw6dB:
+		l,r = ll,lr
+	default:
+		return 0,0,&ParamError{Name: "law", Value: v.Law, Err: ErrInvalidParameter}
+	}
+
+	g := math.Pow(10, v.Gain / 20)
+	return l * g,r * g,nil
+}
+
+// Transform the mono pcm to stereo npcm, where len(npcm)===2*len(pcm),
+// pan to the center by the -3dB pan law.
 // @remark the pcm must be s16le(16bits PCM in little-endian).
 func PcmS16leMono2Stereo(pcm, npcm []byte) (err error) {
-	if len(pcm) == 0 {
-		return fmt.Errorf("PCM empty")
-	}
+	return PcmS16leMono2StereoOptions(pcm, npcm, &Mono2StereoOptions{Law: PanLaw3dB})
+}
+
+// Transform the mono pcm to stereo npcm by the pan options, where len(npcm)===2*len(pcm),
+// nil opts to use the default options, the same to PcmS16leMono2Stereo.
+// @remark the pcm must be s16le(16bits PCM in little-endian).
+func PcmS16leMono2StereoOptions(pcm, npcm []byte, opts *Mono2StereoOptions) (err error) {
 	if (len(pcm) % 2) != 0 {
-		return fmt.Errorf("PCM size=%v not s16le", len(pcm))
+		return &UnalignedError{Name: "PCM", Size: len(pcm), Align: 2}
 	}
 	if len(npcm) != 2*len(pcm) {
-		return fmt.Errorf("NPCM size=%v invalid", len(npcm))
+		return &BufferSizeError{Name: "NPCM", Size: len(npcm), Expect: 2*len(pcm)}
+	}
+
+	if opts == nil {
+		opts = &Mono2StereoOptions{}
+	}
+
+	var gl,gr float64
+	if gl,gr,err = opts.gains(); err != nil {
+		return
 	}
 
 	// The value of pcm is v(16bits int little-endian),
@@ -49,6 +125,9 @@ func PcmS16leMono2Stereo(pcm, npcm []byte) (err error) {
 	// we can use fast int transform:
 	//		v0 = v * 1.4142135623731 / 2
 	//		v0 = v * 0.7071067811865499
+	// which is the -3dB pan law at the center, for other pan laws and positions,
+	// the gain of L and R is different.
+	l,r := float32(gl),float32(gr)
 	for i:=0; i<len(pcm); i+=2 {
 		// 16bits le sample
 		v := (int16(pcm[i])) | (int16(pcm[i+1]) << 8)
@@ -56,16 +135,27 @@ func PcmS16leMono2Stereo(pcm, npcm []byte) (err error) {
 		//		PcmS16leMono2Stereo_int64, loop=8000000, diff=2.188711601s
 		//  	PcmS16leMono2Stereo_float64, loop=8000000, diff=2.017123758s
 		//		PcmS16leMono2Stereo_float32, loop=8000000, diff=1.749901193s
-		v = int16(float32(v) * 0.7071)
+		vl := mono2stereo_sample(float32(v) * l)
+		vr := mono2stereo_sample(float32(v) * r)
 
 		// L
-		npcm[i*2] = byte(v)
-		npcm[i*2 + 1] = byte(v >> 8)
+		npcm[i*2] = byte(vl)
+		npcm[i*2 + 1] = byte(vl >> 8)
 
 		// R
-		npcm[i*2 + 2] = byte(v)
-		npcm[i*2 + 3] = byte(v >> 8)
+		npcm[i*2 + 2] = byte(vr)
+		npcm[i*2 + 3] = byte(vr >> 8)
 	}
 
 	return
 }
+
+// Convert the sample to int16, saturate when the gain exceeds the range.
+func mono2stereo_sample(f float32) int16 {
+	if f > math.MaxInt16 {
+		return math.MaxInt16
+	} else if f < math.MinInt16 {
+		return math.MinInt16
+	}
+	return int16(f)
+}
@@ -0,0 +1,233 @@
+// The MIT License (MIT)
+//
+// Copyright (c) 2016 winlin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+
+// The PCM resample.
+package aresample
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+)
+
+// The max phases of polyphase filter bank, about 8MB coefficients for 128 taps.
+const polyphaseMaxPhases = 8192
+
+// The max bytes of coefficients of the shared filter banks, the least recently used bank is
+// evicted when exceeds, and the bank larger than a quarter of it is never shared.
+const polyphaseMaxCache = 16 * 1024 * 1024
+
+// The shared filter banks, because the bank is read-only after created, and large, for example,
+// about 595KB for QualityVeryHigh from 44100 to 48000, so the resamplers of the same rates and
+// filter share one bank, which is cached in a LRU of polyphaseMaxCache bytes. The evicted bank
+// is freed when the resamplers using it are freed.
+var polyphaseBanks = newPolyphaseCache(polyphaseMaxCache)
+
+// The LRU of shared filter banks, the front is the most recently used.
+type polyphaseCache struct {
+	lock  sync.Mutex
+	banks map[polyphaseKey]*list.Element
+	lru   *list.List
+	size  int // The bytes of coefficients in cache.
+	max   int // The max bytes of coefficients in cache.
+}
+
+type polyphaseEntry struct {
+	key  polyphaseKey
+	bank *polyphaseBank
+}
+
+func newPolyphaseCache(max int) *polyphaseCache {
+	return &polyphaseCache{banks: make(map[polyphaseKey]*list.Element), lru: list.New(), max: max}
+}
+
+// Load the shared bank of key, and mark it as the most recently used.
+func (v *polyphaseCache) load(key polyphaseKey) (*polyphaseBank, bool) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	e,ok := v.banks[key]
+	if !ok {
+		return nil,false
+	}
+	v.lru.MoveToFront(e)
+	return e.Value.(*polyphaseEntry).bank,true
+}
+
+// Store the bank of key, return the shared one if stored by others, and evict
+// the least recently used banks which exceed the max bytes. The large bank is not stored.
+func (v *polyphaseCache) store(key polyphaseKey, bank *polyphaseBank) *polyphaseBank {
+	size := bank.bytes()
+	if size > v.max / 4 {
+		return bank
+	}
+
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	if e,ok := v.banks[key]; ok {
+		v.lru.MoveToFront(e)
+		return e.Value.(*polyphaseEntry).bank
+	}
+
+	v.banks[key] = v.lru.PushFront(&polyphaseEntry{key: key, bank: bank})
+	for v.size += size; v.size > v.max; {
+		e := v.lru.Back()
+		entry := v.lru.Remove(e).(*polyphaseEntry)
+		delete(v.banks, entry.key)
+		v.size -= entry.bank.bytes()
+	}
+	return bank
+}
+
+// The key of shared filter bank.
+type polyphaseKey struct {
+	up, down uint64
+	taps     int
+	cutoff   float64
+	beta     float64
+}
+
+// The polyphase filter bank, for isr/osr reduced to M/L,
+// the output sample n is at position n*M/L of input,
+// so there are only L phases of the windowed-sinc filter,
+// and the phase is the numerator of srPosition.
+type polyphaseBank struct {
+	up   uint64 // The L, the number of phases, the reduced osr.
+	down uint64 // The M, the step of position, the reduced isr.
+
+	before int // The samples before the position.
+	after  int // The samples after the position.
+
+	// The coefficients of phase p is coeffs[p*ntaps:(p+1)*ntaps],
+	// where ntaps is before+after+1.
+	coeffs []float64
+
+	// The windowed-sinc for the position between phases, for example, by SetRatio,
+	// the lookup table is built once when required.
+	sinc  *sincInterpolator
+	table sync.Once
+}
+
+// Create the polyphase filter bank to resample from isr to osr,
+// the taps and cutoff is the same to newSincInterpolator.
+func newPolyphaseBank(isr, osr int, taps int, cutoff float64) (*polyphaseBank, error) {
+	return newPolyphaseKaiser(isr, osr, taps, cutoff, sincKaiserBeta)
+}
+
+// Create the polyphase filter bank like newPolyphaseBank, with the beta of Kaiser window.
+func newPolyphaseKaiser(isr, osr int, taps int, cutoff, beta float64) (*polyphaseBank, error) {
+	sinc,err := newSincWindowed(isr, osr, taps, cutoff, beta)
+	if err != nil {
+		return nil,err
+	}
+
+	g := gcd(uint64(isr), uint64(osr))
+	v := &polyphaseBank{
+		up: uint64(osr) / g,
+		down: uint64(isr) / g,
+		before: sinc.before,
+		after: sinc.after,
+		sinc: sinc,
+	}
+	if v.up > polyphaseMaxPhases {
+		return nil,&ParamError{Name: "phases", Value: fmt.Sprintf("%v, %v/%v", v.up, isr, osr), Err: ErrInvalidSampleRate}
+	}
+
+	key := polyphaseKey{up: v.up, down: v.down, taps: taps, cutoff: cutoff, beta: beta}
+	if bank,ok := polyphaseBanks.load(key); ok {
+		return bank,nil
+	}
+
+	// Precompute each phase, normalized to unity gain at DC.
+	ntaps := v.before + v.after + 1
+	v.coeffs = make([]float64, int(v.up)*ntaps)
+	for p:=0; p<int(v.up); p++ {
+		c := v.coeffs[p*ntaps:(p+1)*ntaps]
+		frac := float64(p) / float64(v.up)
+
+		var sum float64
+		for k := range c {
+			t := frac - float64(k-v.before)
+			if t < 0 {
+				t = -t
+			}
+			c[k] = sinc.kernel(t)
+			sum += c[k]
+		}
+		for k := range c {
+			c[k] /= sum
+		}
+	}
+
+	return polyphaseBanks.store(key, v),nil
+}
+
+// The bytes of coefficients.
+func (v *polyphaseBank) bytes() int {
+	return len(v.coeffs) * 8
+}
+
+func (v *polyphaseBank) support() (before, after int) {
+	return v.before,v.after
+}
+
+// Interpolate by the phase of frac, which is exactly the phase when den is L,
+// otherwise by the windowed-sinc at the position.
+func (v *polyphaseBank) interpolate(w []float64, frac, den uint64) (float64, error) {
+	if den == v.up {
+		return v.filter(w, frac),nil
+	}
+
+	return v.Interpolate(w, float64(frac)/float64(den))
+}
+
+func (v *polyphaseBank) Taps() (before, after int) {
+	return v.support()
+}
+
+// Interpolate at x by the windowed-sinc of the same filter, because the nearest
+// of L phases is not accurate, for example, L is 1 for 48000 to 24000.
+func (v *polyphaseBank) Interpolate(w []float64, x float64) (float64, error) {
+	v.table.Do(v.sinc.build_table)
+	return v.sinc.Interpolate(w, x)
+}
+
+// Filter the window w by the coefficients of phase p.
+func (v *polyphaseBank) filter(w []float64, p uint64) float64 {
+	ntaps := len(w)
+	c := v.coeffs[int(p)*ntaps:(int(p)+1)*ntaps]
+
+	var y float64
+	for k, s := range w {
+		y += s * c[k]
+	}
+	return y
+}
+
+// The greatest common divisor of a and b.
+func gcd(a, b uint64) uint64 {
+	for b != 0 {
+		a,b = b,a%b
+	}
+	return a
+}
@@ -0,0 +1,244 @@
+// The MIT License (MIT)
+//
+// Copyright (c) 2016 winlin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+
+// The PCM resample.
+package aresample
+
+import "math"
+
+// The -3dB gain of ITU-R BS.775, the equal-power gain to mix a speaker to two speakers.
+const remixGain = 0.7071067811865476
+
+// The remixer to convert the pcm from one channel layout to another, for example,
+// downmix the 5.1 to stereo, by a mixing matrix.
+type Remixer struct {
+	layout  ChannelLayout
+	nlayout ChannelLayout
+	// The matrix[o][i] is the gain of input channel i to output channel o.
+	matrix  [][]float64
+}
+
+// Create a remixer from layout to nlayout, use the default matrix by RemixMatrix.
+func NewRemixer(layout, nlayout ChannelLayout) (*Remixer, error) {
+	matrix,err := RemixMatrix(layout, nlayout)
+	if err != nil {
+		return nil,err
+	}
+	return NewRemixerMatrix(layout, nlayout, matrix)
+}
+
+// Create a remixer from layout to nlayout by the matrix, where the matrix[o][i]
+// is the gain of input channel i to output channel o.
+func NewRemixerMatrix(layout, nlayout ChannelLayout, matrix [][]float64) (*Remixer, error) {
+	if err := layout.Validate(); err != nil {
+		return nil,err
+	}
+	if err := nlayout.Validate(); err != nil {
+		return nil,err
+	}
+	if len(matrix) != nlayout.Channels() {
+		return nil,&BufferSizeError{Name: "matrix rows", Size: len(matrix), Expect: nlayout.Channels()}
+	}
+	for _,row := range matrix {
+		if len(row) != layout.Channels() {
+			return nil,&BufferSizeError{Name: "matrix cols", Size: len(row), Expect: layout.Channels()}
+		}
+	}
+
+	v := &Remixer{layout: layout, nlayout: nlayout}
+	for _,row := range matrix {
+		v.matrix = append(v.matrix, append([]float64(nil), row...))
+	}
+	return v,nil
+}
+
+// The default matrix to remix layout to nlayout, by the ITU-R BS.775 downmix:
+//		the speaker in both layouts is copied,
+//		the center is mixed to left and right at -3dB,
+//		the surround is mixed to front at -3dB,
+//		the LFE is dropped.
+// For upmix, the mono is mixed to left and right at -3dB, other speakers are silent.
+// The row whose sum of gains exceeds 1 is normalized, to avoid clipping.
+func RemixMatrix(layout, nlayout ChannelLayout) (matrix [][]float64, err error) {
+	if err = layout.Validate(); err != nil {
+		return nil,err
+	}
+	if err = nlayout.Validate(); err != nil {
+		return nil,err
+	}
+
+	matrix = make([][]float64, nlayout.Channels())
+	for o := range matrix {
+		matrix[o] = make([]float64, layout.Channels())
+	}
+
+	for i,s := range layout.Speakers() {
+		// Copy the speaker in both layouts.
+		if nlayout.Has(s) {
+			matrix[nlayout.Index(s)][i] = 1
+			continue
+		}
+
+		// Mix to the first candidate speakers which all exists in nlayout.
+		for _,c := range remix_candidates(s) {
+			if !nlayout.Has(c.speakers[0]) || (len(c.speakers) > 1 && !nlayout.Has(c.speakers[1])) {
+				continue
+			}
+			for _,t := range c.speakers {
+				matrix[nlayout.Index(t)][i] += c.gain
+			}
+			break
+		}
+	}
+
+	// Normalize the row to avoid clipping.
+	for _,row := range matrix {
+		var sum float64
+		for _,g := range row {
+			sum += math.Abs(g)
+		}
+		if sum > 1 {
+			for i := range row {
+				row[i] /= sum
+			}
+		}
+	}
+
+	return
+}
+
+// The candidate speakers to mix to, when the speaker not in the output layout.
+type remixCandidate struct {
+	speakers []Speaker
+	gain     float64
+}
+
+// The candidates of speaker, in the order of priority.
+func remix_candidates(s Speaker) []remixCandidate {
+	front := []Speaker{SpeakerFrontLeft, SpeakerFrontRight}
+	center := []Speaker{SpeakerFrontCenter}
+	switch s {
+	case SpeakerFrontLeft, SpeakerFrontRight:
+		return []remixCandidate{{center, remixGain}}
+	case SpeakerFrontCenter:
+		return []remixCandidate{{front, remixGain}}
+	case SpeakerFrontLeftOfCenter:
+		return []remixCandidate{{[]Speaker{SpeakerFrontLeft}, 1}, {center, remixGain}}
+	case SpeakerFrontRightOfCenter:
+		return []remixCandidate{{[]Speaker{SpeakerFrontRight}, 1}, {center, remixGain}}
+	case SpeakerBackLeft:
+		return []remixCandidate{{[]Speaker{SpeakerSideLeft}, 1}, {[]Speaker{SpeakerFrontLeft}, remixGain}, {center, 0.5}}
+	case SpeakerBackRight:
+		return []remixCandidate{{[]Speaker{SpeakerSideRight}, 1}, {[]Speaker{SpeakerFrontRight}, remixGain}, {center, 0.5}}
+	case SpeakerSideLeft:
+		return []remixCandidate{{[]Speaker{SpeakerBackLeft}, 1}, {[]Speaker{SpeakerFrontLeft}, remixGain}, {center, 0.5}}
+	case SpeakerSideRight:
+		return []remixCandidate{{[]Speaker{SpeakerBackRight}, 1}, {[]Speaker{SpeakerFrontRight}, remixGain}, {center, 0.5}}
+	case SpeakerBackCenter:
+		return []remixCandidate{
+			{[]Speaker{SpeakerBackLeft, SpeakerBackRight}, remixGain},
+			{[]Speaker{SpeakerSideLeft, SpeakerSideRight}, remixGain},
+			{front, 0.5},
+			{center, remixGain},
+		}
+	}
+	// The LFE is dropped.
+	return nil
+}
+
+// The input layout.
+func (v *Remixer) Layout() ChannelLayout {
+	return v.layout
+}
+
+// The output layout.
+func (v *Remixer) NLayout() ChannelLayout {
+	return v.nlayout
+}
+
+// The mixing matrix, where the matrix[o][i] is the gain of input channel i to output channel o.
+func (v *Remixer) Matrix() [][]float64 {
+	return v.matrix
+}
+
+// Remix the pcm to npcm, where len(npcm)===len(pcm)/layout.Channels()*nlayout.Channels().
+// @remark the pcm must be s16le(16bits PCM in little-endian).
+func (v *Remixer) RemixPcmS16le(pcm, npcm []byte) error {
+	return v.Remix(pcm, npcm, FormatS16LE)
+}
+
+// Remix the pcm to npcm in format, where len(npcm)===len(pcm)/layout.Channels()*nlayout.Channels().
+func (v *Remixer) Remix(pcm, npcm []byte, format SampleFormat) (err error) {
+	if err = format.Validate(); err != nil {
+		return
+	}
+
+	size := format.BytesPerSample()
+	nbChannels,nbNChannels := v.layout.Channels(),v.nlayout.Channels()
+	if (len(pcm) % (size * nbChannels)) != 0 {
+		return &UnalignedError{Name: "PCM", Size: len(pcm), Align: size * nbChannels}
+	}
+	if len(npcm) != len(pcm)/nbChannels*nbNChannels {
+		return &BufferSizeError{Name: "NPCM", Size: len(npcm), Expect: len(pcm)/nbChannels*nbNChannels}
+	}
+
+	frame := make([]float64, nbChannels)
+	for i,n := 0,0; i<len(pcm); i+=size*nbChannels {
+		for ch := range frame {
+			frame[ch] = format.decode(pcm[i+ch*size:])
+		}
+		for _,row := range v.matrix {
+			format.encode(npcm[n:n+size], remix_frame(row, frame))
+			n += size
+		}
+	}
+
+	return
+}
+
+// Remix the interleaved float64 pcm to npcm, where len(npcm)===len(pcm)/layout.Channels()*nlayout.Channels().
+func (v *Remixer) RemixFloat64(pcm, npcm []float64) (err error) {
+	nbChannels,nbNChannels := v.layout.Channels(),v.nlayout.Channels()
+	if (len(pcm) % nbChannels) != 0 {
+		return &UnalignedError{Name: "PCM", Size: len(pcm), Align: nbChannels}
+	}
+	if len(npcm) != len(pcm)/nbChannels*nbNChannels {
+		return &BufferSizeError{Name: "NPCM", Size: len(npcm), Expect: len(pcm)/nbChannels*nbNChannels}
+	}
+
+	for i,n := 0,0; i<len(pcm); i+=nbChannels {
+		for _,row := range v.matrix {
+			npcm[n] = remix_frame(row, pcm[i:i+nbChannels])
+			n++
+		}
+	}
+
+	return
+}
+
+// Mix the frame of input channels to a sample by the row of matrix.
+func remix_frame(row, frame []float64) (s float64) {
+	for i,g := range row {
+		s += g * frame[i]
+	}
+	return
+}
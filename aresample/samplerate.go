@@ -23,171 +23,893 @@
 package aresample
 
 import (
-	"fmt"
+	"math"
+	"math/bits"
+	"time"
 )
 
 type ResampleSampleRate interface {
+	// Resample the float samples, which shares the cache with s16le samples.
+	ResampleFloat
+
 	// Resample the pcm to npcm, which contains len(pcm)/2 samples.
 	// @remark each sample is 16bits in short int.
-	// @reamrk pcm must align to 2, atleast 4 samples.
+	// @remark pcm must align to 2, any number of samples including zero, which are
+	// 		cached until there are enough samples to interpolate.
 	Resample(pcm []byte) (npcm []byte, err error)
+	// Flush the cached samples to npcm, as if the stream is followed by silence,
+	// so the total output is ceil(nbInputSamples*osr/isr) samples.
+	// @remark user can continue to Resample, but the history is lost.
+	Flush() (npcm []byte, err error)
+	// Resample the src to dst like Resample, but never allocate, n is the bytes written to dst,
+	// and consumed is the bytes of src resampled, which is less than len(src) if dst is full.
+	// @remark use OutputSize to get the size of dst.
+	ResampleInto(dst, src []byte) (n int, consumed int, err error)
+	// The exact bytes of output when resample inputBytes, including the cached samples,
+	// that is the PredictOutputSamples in bytes.
+	OutputSize(inputBytes int) int
+	// Set the sample format of input pcm and output npcm, default to s16le,
+	// for example, to resample the s24le to s16be.
+	SetSampleFormat(format, nformat SampleFormat) (err error)
+	// Reset the resampler to the initial state, drop the cached samples,
+	// but keep the kernel and buffers to reuse.
+	Reset()
+	// Reconfigure the channels and sample rates of stream, for example, the publisher
+	// switches codec, the cached samples are resampled by the old rate, and the
+	// output timeline is continuous, so there is no click at the switch point.
+	Reconfigure(channels, sampleRate, nSampleRate int) (err error)
+	// The total output samples clipped, which are saturated to the range of output format,
+	// for example, the overshoot of kernel near the full-scale, to alarm on hot sources.
+	// @remark the float output is never clipped.
+	Clipped() uint64
+	// Set the dither to quantize the output to integer format, nil to truncate,
+	// for example, the TPDF dither when resample the f32le or s24le to s16le.
+	// @remark the dither is never used for float output.
+	SetDither(d *Dither)
+	// The delay of cached samples in 1/base seconds, rounded up, like swr_get_delay, for example,
+	// the base is sampleRate for input samples, nSampleRate for output samples, 1000 for ms.
+	// The cached samples are after the position of next output sample, which are outputed
+	// by next Resample or Flush.
+	Delay(base int64) int64
+	// The delay of cached samples in time, for example, to adjust the timestamp of audio.
+	Latency() time.Duration
+	// The exact output samples of each channel by next Resample of inputSamples of each channel.
+	PredictOutputSamples(inputSamples int) int
+	// Resample the pcm with timestamp pts in timebase, return the output frames with the
+	// mapped pts, which is split at the gap of input timestamps, see ResampleFrame.
+	ResampleFrame(pcm []byte, pts int64, tb Timebase) (frames []Frame, err error)
+	// Flush the cached samples to frames with the mapped pts.
+	FlushFrame() (frames []Frame, err error)
+	// Set the ratio of output to input samples, the nSampleRate/sampleRate by default, which
+	// is changed smoothly from the position of next output sample without discontinuity,
+	// for example, nudged by the controller of jitter buffer for adaptive playout.
+	// @remark the kernel is not changed, use Reconfigure for large change.
+	// @remark Reset and Reconfigure restore the ratio to nSampleRate/sampleRate.
+	SetRatio(ratio float64) (err error)
+	// Set the ratio by the effective output rate, that is SetRatio(rate/sampleRate).
+	SetOutputRate(rate float64) (err error)
+	// The ratio of output to input samples.
+	Ratio() float64
 }
 
+// The max channels of resampler.
+const maxChannels = 64
+
+// The max ratio of SetRatio, the ratio is in [1/srMaxRatio, srMaxRatio].
+const srMaxRatio = 256
+
+// The denominator of step for SetRatio, about 0.06ppm resolution.
+const srRatioDen = 1 << 24
+
 // sample rate resampler.
 type srResampler struct {
-	channels int     // Channels, L or LR
+	channels int     // Channels, L or LR, or more for 5.1 and 7.1
 	isr      int     // Transform from this sample rate.
 	osr      int     // Transform to this sample rate.
+	format   SampleFormat // The format of input pcm.
+	nformat  SampleFormat // The format of output npcm.
+	interp   interpolator // The kernel to interpolate samples.
+	create   func(isr, osr int) (interpolator, error) // Create the kernel for rates.
+	sw       *srSwitch // The switch point of reconfigure, nil if not switching.
+	chs      []*srChannel // The state of each channel.
+	opcms    [][]float64  // The output samples of each channel, reuse for each call.
+	clipped  uint64 // Total clipped output samples.
+	dither   *Dither // The dither to quantize output, nil to truncate.
+	anchors  []srAnchor // The timestamps of input frames, to map the pts of output.
+	num      uint64  // The step of output sample is num/den input samples,
+	den      uint64  // 	which is the reduced isr/osr, or set by SetRatio.
+}
 
-					 // Always cache 16samples.
-	lcache   []int16 // For channel=0
-	rcache   []int16 // For channel=1
-
-					 // Total outputed samples.
-	lws      uint64  // For channel=0
-	rws      uint64  // For channel=1
-
-					 // Total consumed samples.
-	lcs      uint64  // For channel=0
-	rcs      uint64  // For channel=1
+// The state of channel.
+type srChannel struct {
+	cache []float64  // Always cache 16samples, in the scale of int16.
+	ws    uint64     // Total outputed samples.
+	pos   srPosition // The exact position of next output sample.
+	cs    uint64     // Total consumed samples, the position of cache.
+	opcm  []float64  // The buffer of output samples, reuse for each call.
 }
 
 // Create resampler to transform pcm
 // from sampleRate to nSampleRate, where pcm contains number of channels
 // @remark each sample is 16bits in short int.
 func NewPcmS16leResampler(channels, sampleRate int, nSampleRate int) (ResampleSampleRate, error) {
-	if channels < 1 || channels > 2 {
-		return nil,fmt.Errorf("invalid channels=%v", channels)
+	if channels < 1 || channels > maxChannels {
+		return nil,&ParamError{Name: "channels", Value: channels, Err: ErrInvalidChannels}
 	}
 	if sampleRate <= 0 {
-		return nil,fmt.Errorf("invalid sampleRate=%v", sampleRate)
+		return nil,&ParamError{Name: "sampleRate", Value: sampleRate, Err: ErrInvalidSampleRate}
 	}
 	if nSampleRate <= 0 {
-		return nil,fmt.Errorf("invalid nSampleRate=%v", nSampleRate)
+		return nil,&ParamError{Name: "nSampleRate", Value: nSampleRate, Err: ErrInvalidSampleRate}
 	}
 
 	v := &srResampler{
 		channels: channels,
 		isr: sampleRate,
 		osr: nSampleRate,
+		format: FormatS16LE,
+		nformat: FormatS16LE,
+		interp: &splineInterpolator{},
+		create: func(isr, osr int) (interpolator, error) {
+			return &splineInterpolator{},nil
+		},
+	}
+	p := newPosition(sampleRate, nSampleRate, 0)
+	v.num,v.den = p.num,p.den
+	for i:=0; i<channels; i++ {
+		v.chs = append(v.chs, &srChannel{pos: p})
 	}
 
 	return v,nil
 }
 
-func (v *srResampler) Resample(pcm []byte) (npcm []byte, err error) {
-	if len(pcm) == 0 {
-		return nil,fmt.Errorf("empty pcm")
+// Create resampler like NewPcmS16leResampler, but use the kernel to interpolate,
+// for example, KernelLinear or KernelSinc(64, 0.9), nil to use KernelSpline.
+// @remark each sample is 16bits in short int.
+func NewPcmS16leKernelResampler(channels, sampleRate, nSampleRate int, kernel KernelFunc) (ResampleSampleRate, error) {
+	r,err := NewPcmS16leResampler(channels, sampleRate, nSampleRate)
+	if err != nil || kernel == nil {
+		return r,err
+	}
+
+	v := r.(*srResampler)
+	v.create = func(isr, osr int) (interpolator, error) {
+		return newKernelInterpolator(kernel, isr, osr)
+	}
+	if v.interp,err = v.create(sampleRate, nSampleRate); err != nil {
+		return nil,err
+	}
+
+	return v,nil
+}
+
+// Create resampler like NewPcmS16leResampler, but use the windowed-sinc(Kaiser)
+// band-limited kernel, which applies the anti-alias low-pass filter when downsampling.
+// The taps is the length of filter in samples, which is scaled by isr/osr when downsampling.
+// The cutoff is the normalized cutoff frequency in (0,1], relative to the Nyquist of the lower rate.
+// @remark each sample is 16bits in short int.
+func NewPcmS16leSincResampler(channels, sampleRate, nSampleRate int, taps int, cutoff float64) (ResampleSampleRate, error) {
+	return NewPcmS16leKernelResampler(channels, sampleRate, nSampleRate, KernelSinc(taps, cutoff))
+}
+
+// Create resampler like NewPcmS16leSincResampler, but use the polyphase filter bank,
+// which reduces the sampleRate/nSampleRate to L/M and precomputes the L phases of filter,
+// so it's faster and exactly periodic, for example, 44100 to 48000 is 160/147.
+// @remark each sample is 16bits in short int.
+func NewPcmS16lePolyphaseResampler(channels, sampleRate, nSampleRate int, taps int, cutoff float64) (ResampleSampleRate, error) {
+	return NewPcmS16leKernelResampler(channels, sampleRate, nSampleRate, KernelPolyphase(taps, cutoff))
+}
+
+// The config to create resampler.
+type Config struct {
+	Channels    int          // The channels of pcm.
+	SampleRate  int          // Transform from this sample rate.
+	NSampleRate int          // Transform to this sample rate.
+	Format      SampleFormat // The format of input pcm, default to s16le.
+	NFormat     SampleFormat // The format of output npcm, default to s16le.
+	Dither      *Dither      // The dither to quantize output, nil to truncate.
+	Kernel      KernelFunc   // The kernel to interpolate, default to KernelSpline.
+	Quality     Quality      // The quality preset, ignored if Kernel is set.
+}
+
+// The kernel of config, use the quality preset if not set.
+func (v *Config) kernel() KernelFunc {
+	if v.Kernel == nil && v.Quality != 0 {
+		return v.Quality.Kernel()
+	}
+	return v.Kernel
+}
+
+// The format of input and output, default to s16le.
+func (v *Config) formats() (format, nformat SampleFormat) {
+	format,nformat = v.Format,v.NFormat
+	if format == (SampleFormat{}) {
+		format = FormatS16LE
 	}
-	if (len(pcm)%(2*v.channels)) != 0 {
-		return nil,fmt.Errorf("invalid pcm, should mod(%v)", 2*v.channels)
+	if nformat == (SampleFormat{}) {
+		nformat = FormatS16LE
 	}
+	return
+}
 
-	if v.isr == v.osr {
+// Create resampler by config.
+func NewResampler(cfg Config) (ResampleSampleRate, error) {
+	r,err := NewPcmS16leKernelResampler(cfg.Channels, cfg.SampleRate, cfg.NSampleRate, cfg.kernel())
+	if err != nil {
+		return nil,err
+	}
+
+	format,nformat := cfg.formats()
+	if err = r.SetSampleFormat(format, nformat); err != nil {
+		return nil,err
+	}
+	r.SetDither(cfg.Dither)
+
+	return r,nil
+}
+
+func (v *srResampler) Resample(pcm []byte) (npcm []byte, err error) {
+	if err = v.validate(pcm); err != nil {
+		return nil,err
+	}
+
+	// Bypass when no cached samples of previous rate.
+	if v.bypass() {
+		if v.format != v.nformat {
+			npcm = make([]byte, len(pcm)/v.format.BytesPerSample()*v.nformat.BytesPerSample())
+			v.clipped += resample_convert(npcm, pcm, v.format, v.nformat, v.channels, v.dither)
+			return npcm,nil
+		}
 		return pcm[:],nil
 	}
 
-	// Atleast 4samples when not init.
-	if nbSamles := len(pcm) / 2 / v.channels; nbSamles < 4 {
-		return nil,fmt.Errorf("invalid pcm, atleast 4samples, actual %vsamples", nbSamles)
+	if err = v.resample_pcm(pcm); err != nil {
+		return nil,err
+	}
+
+	// Convert samples to bytes.
+	npcm = make([]byte, len(v.opcms[0])*v.channels*v.nformat.BytesPerSample())
+	_,clipped := resample_merge_format(npcm, v.opcms, v.nformat, v.dither)
+	v.clipped += clipped
+
+	return
+}
+
+func (v *srResampler) ResampleInto(dst, src []byte) (n int, consumed int, err error) {
+	if err = v.validate(src); err != nil {
+		return 0,0,err
+	}
+
+	// Bypass when no cached samples of previous rate.
+	frame := v.format.BytesPerSample()*v.channels
+	if v.bypass() && v.format == v.nformat {
+		if len(dst) < frame && frame <= len(src) {
+			return 0,0,&BufferSizeError{Name: "dst", Size: len(dst), Expect: frame}
+		}
+		n = copy(dst[:len(dst)/frame*frame], src)
+		return n,n,nil
+	}
+
+	// Consume the most samples which the output fits in dst, search it because
+	// the output size is monotonic.
+	nbSamples := len(src) / frame
+	if v.OutputSize(nbSamples*frame) > len(dst) {
+		fit,overflow := 0,nbSamples
+		for fit+1 < overflow {
+			if mid := (fit+overflow)/2; v.OutputSize(mid*frame) <= len(dst) {
+				fit = mid
+			} else {
+				overflow = mid
+			}
+		}
+		nbSamples = fit
+	}
+	consumed = nbSamples*frame
+	if (nbSamples == 0 && frame <= len(src)) || v.OutputSize(consumed) > len(dst) {
+		return 0,0,&BufferSizeError{Name: "dst", Size: len(dst), Expect: v.OutputSize(frame)}
+	}
+	// Never consume the lookahead into a dst which never fits any output frame.
+	if nframe := v.nformat.BytesPerSample()*v.channels; len(dst) < nframe && 0 < consumed {
+		return 0,0,&BufferSizeError{Name: "dst", Size: len(dst), Expect: nframe}
+	}
+
+	if v.bypass() {
+		n = nbSamples*v.channels*v.nformat.BytesPerSample()
+		v.clipped += resample_convert(dst[:n], src[:consumed], v.format, v.nformat, v.channels, v.dither)
+		return
+	}
+
+	if err = v.resample_pcm(src[:consumed]); err != nil {
+		return 0,0,err
+	}
+
+	// Convert samples to bytes.
+	var clipped uint64
+	n,clipped = resample_merge_format(dst, v.opcms, v.nformat, v.dither)
+	v.clipped += clipped
+
+	return
+}
+
+func (v *srResampler) OutputSize(inputBytes int) int {
+	frame,nframe := v.format.BytesPerSample()*v.channels,v.nformat.BytesPerSample()*v.channels
+	if v.bypass() {
+		return inputBytes/frame*nframe
 	}
 
-	// Convert pcm to int16 values
-	ipcmLeft := resampler_init_channel(pcm, v.channels, 0)
-	ipcmRight := resampler_init_channel(pcm, v.channels, 1)
-	if ipcmRight != nil && len(ipcmLeft) != len(ipcmRight) {
-		return nil,fmt.Errorf("invalid pcm, L%v!=%v", len(ipcmLeft), len(ipcmRight))
+	return v.predict(inputBytes/frame)*nframe
+}
+
+func (v *srResampler) SetSampleFormat(format, nformat SampleFormat) (err error) {
+	if err = format.Validate(); err != nil {
+		return err
+	}
+	if err = nformat.Validate(); err != nil {
+		return err
 	}
 
-	// Insert the cache at the beginning.
-	if v.lcache != nil {
-		ipcmLeft = append(v.lcache, ipcmLeft...)
-		v.lcache = nil
+	v.format,v.nformat = format,nformat
+	return
+}
+
+// Validate the pcm of Resample.
+func (v *srResampler) validate(pcm []byte) error {
+	frame := v.format.BytesPerSample()*v.channels
+	if (len(pcm)%frame) != 0 {
+		return &UnalignedError{Name: "pcm", Size: len(pcm), Align: frame}
 	}
-	if ipcmRight != nil && v.rcache != nil {
-		ipcmRight = append(v.rcache, ipcmRight...)
-		v.rcache = nil
+
+	return nil
+}
+
+// Whether bypass the pcm, when no cached samples of previous rate, and the ratio is not set.
+func (v *srResampler) bypass() bool {
+	return v.isr == v.osr && v.num == v.den && v.sw == nil && len(v.chs[0].cache) == 0
+}
+
+// Resample the pcm to the output opcms, reuse all buffers.
+func (v *srResampler) resample_pcm(pcm []byte) (err error) {
+	// Append pcm to the cache.
+	for i,c := range v.chs {
+		c.cache = resample_split_format(c.cache, pcm, v.format, v.channels, i)
 	}
 
+	return v.resample_cache()
+}
+
+// Resample the cache to the output opcms.
+func (v *srResampler) resample_cache() (err error) {
 	// Resample all channels
 	var consumed int
-	var opcmLeft []int16
-	if opcmLeft,consumed,err = resample_channel(ipcmLeft,v.isr,v.osr,v.lws,v.lcs); err != nil {
+	var switched bool
+	before := v.history()
+	v.opcms = v.opcms[:0]
+	for _,c := range v.chs {
+		if c.opcm,consumed,c.pos,switched,err = v.resample(c.opcm[:0],c.cache,c.pos,c.cs); err != nil {
+			return
+		}
+		consumed = resample_history(consumed, before)
+		c.ws += uint64(len(c.opcm))
+		c.cs += uint64(consumed)
+		c.cache = c.cache[:copy(c.cache, c.cache[consumed:])]
+		v.opcms = append(v.opcms, c.opcm)
+	}
+
+	if switched {
+		v.sw = nil
+	}
+
+	return
+}
+
+func (v *srResampler) Flush() (npcm []byte, err error) {
+	if err = v.flush_cache(); err != nil {
 		return nil,err
 	}
-	v.lws += uint64(len(opcmLeft))
-	v.lcs += uint64(consumed)
-	if consumed < len(ipcmLeft) {
-		v.lcache = ipcmLeft[consumed:]
+
+	// Convert samples to bytes.
+	npcm = make([]byte, len(v.opcms[0])*v.channels*v.nformat.BytesPerSample())
+	_,clipped := resample_merge_format(npcm, v.opcms, v.nformat, v.dither)
+	v.clipped += clipped
+
+	return
+}
+
+// Flush the cache to the output opcms.
+func (v *srResampler) flush_cache() (err error) {
+	// Pad silence for the lookahead of last sample.
+	_,after := v.interp.support()
+	if v.sw != nil {
+		if _,a := v.sw.interp.support(); a > after {
+			after = a
+		}
+	}
+	padding := make([]float64, resample_lookahead(after))
+
+	var switched bool
+	v.opcms = v.opcms[:0]
+	for _,c := range v.chs {
+		c.opcm = c.opcm[:0]
+		if len(c.cache) > 0 {
+			ipcm := append(c.cache, padding...)
+			if c.opcm,_,c.pos,switched,err = v.resample(c.opcm,ipcm,c.pos,c.cs); err != nil {
+				return err
+			}
+			c.ws += uint64(len(c.opcm))
+			c.cs += uint64(len(c.cache))
+			c.cache = c.cache[:0]
+		}
+		v.opcms = append(v.opcms, c.opcm)
+	}
+
+	if switched {
+		v.sw = nil
+	}
+
+	return
+}
+
+func (v *srResampler) Reset() {
+	// Keep the buffer of cache to reuse.
+	p := newPosition(v.isr, v.osr, 0)
+	v.num,v.den = p.num,p.den
+	for _,c := range v.chs {
+		c.cache = c.cache[:0]
+		c.ws,c.cs = 0,0
+		c.pos = p
+	}
+	v.sw = nil
+	v.clipped = 0
+	v.anchors = v.anchors[:0]
+}
+
+func (v *srResampler) Clipped() uint64 {
+	return v.clipped
+}
+
+func (v *srResampler) SetDither(d *Dither) {
+	v.dither = d
+}
+
+func (v *srResampler) Delay(base int64) int64 {
+	// The position after the switch point is in the new rate.
+	c,p := v.chs[0],v.chs[0].pos
+	if v.sw != nil && p.pos >= v.sw.pos {
+		p = v.sw.convert(p, v.isr, v.num, v.den)
+	}
+
+	end := c.cs + uint64(len(c.cache))
+	if base <= 0 || end <= p.pos {
+		return 0
+	}
+
+	// The delay is ((end-pos)*den-frac)/den input samples, that is x/(den*isr) seconds.
+	if v.sw == nil || p.pos >= v.sw.pos {
+		q,r := resample_muldiv((end-p.pos)*p.den - p.frac, uint64(base), p.den*uint64(v.isr))
+		if r > 0 {
+			q++
+		}
+		return int64(q)
+	}
+
+	// While switching, the samples before the switch point are in the old rate, that is
+	// x0/d0 seconds, and the samples after it are in the new rate, that is x1/d1 seconds.
+	d0,d1 := p.den*uint64(v.sw.isr),uint64(v.isr)
+	q0,r0 := resample_muldiv((v.sw.pos-p.pos)*p.den - p.frac, uint64(base), d0)
+	q1,r1 := resample_muldiv(end-v.sw.pos, uint64(base), d1)
+
+	// Round up the sum of remainders r0/d0+r1/d1, which is less than 2.
+	q := q0 + q1
+	if r0 > 0 || r1 > 0 {
+		q++
+	}
+	hi0,lo0 := bits.Mul64(r0, d1)
+	hi1,lo1 := bits.Mul64(r1, d0)
+	lo,carry := bits.Add64(lo0, lo1, 0)
+	hi := hi0 + hi1 + carry
+	if dhi,dlo := bits.Mul64(d0, d1); hi > dhi || (hi == dhi && lo > dlo) {
+		q++
+	}
+	return int64(q)
+}
+
+func (v *srResampler) Latency() time.Duration {
+	return time.Duration(v.Delay(int64(time.Second)))
+}
+
+func (v *srResampler) SetRatio(ratio float64) (err error) {
+	if math.IsNaN(ratio) || ratio < 1.0/srMaxRatio || ratio > srMaxRatio {
+		return &ParamError{Name: "ratio", Value: ratio, Err: ErrInvalidParameter}
+	}
+
+	// Use the exact reduced rates for the nominal ratio, for example, the polyphase
+	// requires the den to be the phases.
+	p := newPosition(v.isr, v.osr, 0)
+	if ratio != float64(v.osr)/float64(v.isr) {
+		p.num,p.den = uint64(math.Floor(srRatioDen/ratio + 0.5)),srRatioDen
+	}
+
+	// Rescale the fraction of position to the new step, so the position is continuous.
+	// While switching, the position before the switch point keeps the step of old rate,
+	// and steps by the new one after converted at the switch point.
+	if v.sw == nil {
+		for _,c := range v.chs {
+			c.pos = c.pos.rescale(p.num, p.den)
+		}
+	}
+	v.num,v.den = p.num,p.den
+	return
+}
+
+func (v *srResampler) SetOutputRate(rate float64) (err error) {
+	return v.SetRatio(rate/float64(v.isr))
+}
+
+func (v *srResampler) Ratio() float64 {
+	return float64(v.den)/float64(v.num)
+}
+
+func (v *srResampler) PredictOutputSamples(inputSamples int) int {
+	if inputSamples <= 0 {
+		return 0
+	}
+	return v.predict(inputSamples)
+}
+
+// The exact output samples of each channel, from the position of next output sample,
+// when the cache and inputSamples are resampled, excluding the lookahead.
+func (v *srResampler) predict(inputSamples int) int {
+	if v.bypass() {
+		return inputSamples
+	}
+
+	c := v.chs[0]
+	end := c.cs + uint64(len(c.cache)) + uint64(inputSamples)
+	if v.sw == nil {
+		return int(c.pos.count(end, v.interp))
+	}
+
+	// The samples before the switch point by the old rate.
+	_,after := v.sw.interp.support()
+	n := c.pos.count(end, v.sw.interp)
+	if sw := v.sw.pos + uint64(resample_lookahead(after)); sw < end {
+		n = c.pos.count(sw, v.sw.interp)
+	}
+	np := c.pos.advance(n)
+	if np.pos < v.sw.pos {
+		return int(n)
 	}
 
-	var opcmRight []int16
-	if ipcmRight != nil {
-		if opcmRight,consumed,err = resample_channel(ipcmRight,v.isr,v.osr,v.rws,v.rcs); err != nil {
-			return nil,err
+	// The samples after the switch point by the new rate.
+	np = v.sw.convert(np, v.isr, v.num, v.den)
+	return int(n + np.count(end, v.interp))
+}
+
+func (v *srResampler) Reconfigure(channels, sampleRate, nSampleRate int) (err error) {
+	if channels < 1 || channels > maxChannels {
+		return &ParamError{Name: "channels", Value: channels, Err: ErrInvalidChannels}
+	}
+	if sampleRate <= 0 {
+		return &ParamError{Name: "sampleRate", Value: sampleRate, Err: ErrInvalidSampleRate}
+	}
+	if nSampleRate <= 0 {
+		return &ParamError{Name: "nSampleRate", Value: nSampleRate, Err: ErrInvalidSampleRate}
+	}
+
+	// Reuse the kernel when rates not changed.
+	interp := v.interp
+	if sampleRate != v.isr || nSampleRate != v.osr {
+		if interp,err = v.create(sampleRate, nSampleRate); err != nil {
+			return err
 		}
-		v.rws += uint64(len(opcmRight))
-		v.rcs += uint64(consumed)
-		if consumed < len(ipcmRight) {
-			v.rcache = ipcmRight[consumed:]
+	}
+
+	// Switch at the first sample of new rate, the end of cache,
+	// and if already switching, the samples after it use the new rate.
+	if v.sw == nil {
+		v.sw = &srSwitch{
+			pos: v.chs[0].cs + uint64(len(v.chs[0].cache)),
+			isr: v.isr,
+			interp: v.interp,
+		}
+	}
+
+	// Convert the cache of channels, to mono by average, to more channels by copy.
+	if channels == 1 && v.channels > 1 {
+		c := v.chs[0]
+		for i := range c.cache {
+			for _,o := range v.chs[1:] {
+				c.cache[i] += o.cache[i]
+			}
+			c.cache[i] /= float64(v.channels)
+		}
+		v.chs = v.chs[:1]
+	} else if channels < v.channels {
+		v.chs = v.chs[:channels]
+	}
+	for i:=v.channels; i<channels; i++ {
+		c := *v.chs[i%v.channels]
+		c.cache,c.opcm = append([]float64(nil), c.cache...),nil
+		v.chs = append(v.chs, &c)
+	}
+
+	v.channels,v.isr,v.osr,v.interp = channels,sampleRate,nSampleRate,interp
+	p := newPosition(sampleRate, nSampleRate, 0)
+	v.num,v.den = p.num,p.den
+	return
+}
+
+// The samples of history to keep, for kernel of both rates when switching.
+func (v *srResampler) history() int {
+	before,_ := v.interp.support()
+	if v.sw != nil {
+		if b,_ := v.sw.interp.support(); b > before {
+			before = b
+		}
+	}
+	return before
+}
+
+// Resample the channel ipcm at org from p, switch to the new rate at the switch point.
+func (v *srResampler) resample(opcm, ipcm []float64, p srPosition, org uint64) (nopcm []float64, consumed int, np srPosition, switched bool, err error) {
+	if v.sw == nil {
+		nopcm,consumed,np,err = resample_channel(opcm,ipcm,p,org,v.interp)
+		return
+	}
+
+	// Resample the samples before the switch point by the old rate.
+	_,after := v.sw.interp.support()
+	old := ipcm
+	if end := int(v.sw.pos-org) + resample_lookahead(after); end < len(old) {
+		old = old[:end]
+	}
+	if nopcm,consumed,np,err = resample_channel(opcm,old,p,org,v.sw.interp); err != nil {
+		return
+	}
+	if np.pos < v.sw.pos {
+		return
+	}
+
+	// Resample the samples after the switch point by the new rate.
+	np = v.sw.convert(np, v.isr, v.num, v.den)
+	switched = true
+
+	var nconsumed int
+	if nopcm,nconsumed,np,err = resample_channel(nopcm,ipcm,np,org,v.interp); err != nil {
+		return
+	}
+	if nconsumed > 0 {
+		consumed = nconsumed
+	}
+
+	return
+}
+
+// The switch point of reconfigure.
+type srSwitch struct {
+	pos    uint64       // The position of the first sample of new rate.
+	isr    int          // The old sample rate before switch point.
+	interp interpolator // The old kernel before switch point.
+}
+
+// Convert the position p of old rate, which is after the switch point,
+// to the position of new rate isr, which steps num/den, keep the time after the switch point.
+func (v *srSwitch) convert(p srPosition, isr int, num, den uint64) (np srPosition) {
+	np = srPosition{num: num, den: den}
+
+	// The time after the switch point, in samples of new rate.
+	x := (float64(p.pos-v.pos) + float64(p.frac)/float64(p.den)) * float64(isr) / float64(v.isr)
+	pos := math.Floor(x)
+	frac := uint64(math.Floor((x-pos)*float64(np.den) + 0.5))
+	np.pos,np.frac = v.pos+uint64(pos),frac
+	if np.frac >= np.den {
+		np.pos,np.frac = np.pos+1,np.frac-np.den
+	}
+
+	return
+}
+
+// merge the samples of channels.
+func resample_merge(opcms [][]float64) (npcm []byte) {
+	npcm = make([]byte, 2*len(opcms[0])*len(opcms))
+	resample_merge_to(npcm, opcms, nil)
+	return
+}
+
+// merge the samples of channels to npcm, return the bytes written and the clipped samples,
+// quantize by the dither if not nil.
+func resample_merge_to(npcm []byte, opcms [][]float64, d *Dither) (n int, clipped uint64) {
+	for i:=0; i<len(opcms[0]); i++ {
+		for ch,opcm := range opcms {
+			s := opcm[i]
+			if d != nil {
+				s = d.quantize(s, ch)
+			}
+			v,c := resample_saturate(s)
+			if c {
+				clipped++
+			}
+			npcm[n] = byte(v)
+			npcm[n+1] = byte(v >> 8)
+			n += 2
 		}
 	}
+	return
+}
 
-	// Convert int16 samples to bytes.
-	npcm = resample_merge(opcmLeft, opcmRight)
+// merge the samples of channels in the format to npcm, return the bytes written and the clipped samples.
+func resample_merge_format(npcm []byte, opcms [][]float64, format SampleFormat, d *Dither) (n int, clipped uint64) {
+	if format == FormatS16LE {
+		return resample_merge_to(npcm, opcms, d)
+	}
 
+	size := format.BytesPerSample()
+	for i:=0; i<len(opcms[0]); i++ {
+		for ch,opcm := range opcms {
+			if format.encode_dither(npcm[n:n+size], opcm[i]/s16Scale, d, ch) {
+				clipped++
+			}
+			n += size
+		}
+	}
 	return
 }
 
-// merge left and right(can be nil).
-func resample_merge(left,right []int16) (npcm []byte) {
-	npcm = []byte{}
-	for i:=0; i<len(left); i++ {
-		v := left[i]
-		npcm = append(npcm, byte(v))
-		npcm = append(npcm, byte(v >> 8))
+// Convert the sample in the scale of int16 to int16, saturate to the range
+// instead of wrap-around to the opposite sign, return whether clipped.
+func resample_saturate(v float64) (int16, bool) {
+	if v >= math.MaxInt16+1 {
+		return math.MaxInt16,true
+	} else if v <= math.MinInt16-1 {
+		return math.MinInt16,true
+	}
+	return int16(v),false
+}
 
-		if right != nil {
-			v = right[i]
-			npcm = append(npcm, byte(v))
-			npcm = append(npcm, byte(v >> 8))
+// Convert the pcm of channels from format to nformat in npcm, which is large enough,
+// quantize by the dither if not nil, return the clipped samples.
+func resample_convert(npcm, pcm []byte, format, nformat SampleFormat, channels int, d *Dither) (clipped uint64) {
+	size,nsize := format.BytesPerSample(),nformat.BytesPerSample()
+	for i,j := 0,0; i<len(pcm); i,j = i+size,j+nsize {
+		if nformat.encode_dither(npcm[j:j+nsize], format.decode(pcm[i:i+size]), d, (i/size)%channels) {
+			clipped++
 		}
 	}
 	return
 }
 
-// x is the position of output pcm
-func resample_channel(ipcm []int16, isr,osr int, written,org uint64) (opcm []int16, consumed int, err error) {
-	if len(ipcm) <= 16 {
-		return nil,0,nil
+// Keep the history samples required by interpolator, return the samples to drop.
+func resample_history(consumed int, before int) int {
+	if consumed -= before; consumed < 0 {
+		return 0
+	}
+	return consumed
+}
+
+// The quotient and remainder of x*base/d, without overflow of x*base.
+func resample_muldiv(x, base, d uint64) (q, r uint64) {
+	hi,lo := bits.Mul64(x, base)
+	return bits.Div64(hi, lo, d)
+}
+
+// Always cache 16samples, or more for long kernel.
+func resample_lookahead(after int) int {
+	if after >= 16 {
+		return after + 1
+	}
+	return 16
+}
+
+// The window of samples around ipcm[yi0], pad zero at the start of stream.
+func resample_window(ipcm []float64, yi0, before, after int) (w []float64) {
+	if yi0 >= before {
+		return ipcm[yi0-before:yi0+after+1]
+	}
+
+	w = make([]float64, before+after+1)
+	copy(w[before-yi0:], ipcm[:yi0+after+1])
+	return
+}
+
+// The exact position in input samples, which is pos+frac/den,
+// where each output sample steps num/den, the reduced isr/osr,
+// so it's never drift for long stream, and not depends on the chunks.
+type srPosition struct {
+	pos  uint64 // The integer part of position.
+	frac uint64 // The numerator of fraction part, in [0,den).
+	num  uint64 // The step numerator, the reduced isr.
+	den  uint64 // The step denominator, the reduced osr.
+}
+
+// Create the position of output sample written, that is written*isr/osr.
+func newPosition(isr,osr int, written uint64) (p srPosition) {
+	g := gcd(uint64(isr), uint64(osr))
+	p.num,p.den = uint64(isr)/g,uint64(osr)/g
+
+	hi,lo := bits.Mul64(written, p.num)
+	p.pos,p.frac = bits.Div64(hi, lo, p.den)
+	return
+}
+
+// Step to the position of next output sample.
+func (v *srPosition) next() {
+	v.frac += v.num
+	v.pos += v.frac / v.den
+	v.frac %= v.den
+}
+
+// The position which steps num/den, the fraction is rescaled to den, rounded to nearest.
+func (v srPosition) rescale(num, den uint64) srPosition {
+	hi,lo := bits.Mul64(v.frac, den)
+	lo,carry := bits.Add64(lo, v.den/2, 0)
+	frac,_ := bits.Div64(hi+carry, lo, v.den)
+	if frac >= den {
+		v.pos,frac = v.pos+1,frac-den
+	}
+	v.frac,v.num,v.den = frac,num,den
+	return v
+}
+
+// The position after n output samples.
+func (v srPosition) advance(n uint64) srPosition {
+	hi,lo := bits.Mul64(n, v.num)
+	lo,carry := bits.Add64(lo, v.frac, 0)
+	q,r := bits.Div64(hi+carry, lo, v.den)
+	v.pos,v.frac = v.pos+q,r
+	return v
+}
+
+// The number of output samples from the position, when the input samples end at end,
+// that is the positions before end-lookahead, see resample_channel.
+func (v srPosition) count(end uint64, interp interpolator) uint64 {
+	_,after := interp.support()
+	lookahead := uint64(resample_lookahead(after))
+	if end <= lookahead {
+		return 0
+	}
+	return v.before(end-lookahead)
+}
+
+// The number of output samples from the position, which are before the last.
+func (v srPosition) before(last uint64) uint64 {
+	if last <= v.pos {
+		return 0
+	}
+
+	// The n is the min k that pos+(frac+k*num)/den >= last.
+	x := (last-v.pos)*v.den - v.frac
+	return (x + v.num - 1) / v.num
+}
+
+// The x is the position of output pcm, from p, append the output to opcm.
+func resample_channel(opcm, ipcm []float64, p srPosition, org uint64, interp interpolator) (nopcm []float64, consumed int, np srPosition, err error) {
+	np,nopcm = p,opcm
+
+	before,after := interp.support()
+	lookahead := resample_lookahead(after)
+	if len(ipcm) <= lookahead {
+		return
 	}
 
 	// The samples we can use to resample
-	available := len(ipcm) - 16
-	// The resample step between new samples
-	step := float64(isr)/float64(osr)
-	// The first position to sample
-	x0 := step*float64(written)
+	available := len(ipcm) - lookahead
 
 	// The position for the last sample.
 	last := org + uint64(available)
 
-	// Resample each position from x0
-	for x:=x0; x < float64(last); x+=step {
-		// Generate xi,yi,xo,yo
-		xi0 := float64(uint64(x))
-		xi := []float64{xi0, xi0+1, xi0+2, xi0+3}
-		yi0 := int(uint64(xi0)-org)
-		yi := []float64{float64(ipcm[yi0]),float64(ipcm[yi0+1]),float64(ipcm[yi0+2]),float64(ipcm[yi0+3])}
-		xo := []float64{x}
-		yo := []float64{0.0}
-		if err = spline(xi,yi,xo,yo); err != nil {
+	// Resample each position from p
+	for ; np.pos < last; np.next() {
+		// The window of samples around x.
+		yi0 := int(np.pos-org)
+		w := resample_window(ipcm, yi0, before, after)
+
+		var yo float64
+		if yo,err = interp.interpolate(w, np.frac, np.den); err != nil {
 			return
 		}
 
-		// convert yo
-		opcm = append(opcm, int16(yo[0]))
-		consumed = int(uint64(x)-org) + 1
+		nopcm = append(nopcm, yo)
+		consumed = yi0 + 1
 	}
 
 	return
@@ -196,19 +918,74 @@ func resample_channel(ipcm []int16, isr,osr int, written,org uint64) (opcm []int
 // resampler_init_channel([]byte{...}, 1, 0)
 // resampler_init_channel([]byte{...}, 2, 0)
 // resampler_init_channel([]byte{...}, 2, 1)
-func resampler_init_channel(pcm []byte, channels, channel int) (ipcm []int16) {
+func resampler_init_channel(pcm []byte, channels, channel int) (ipcm []float64) {
 	if channel >= channels {
 		return
 	}
 
-	ipcm = []int16{}
+	return resample_split([]float64{}, pcm, channels, channel)
+}
+
+// Split the channel of pcm in the format, append to ipcm in the scale of int16.
+func resample_split_format(ipcm []float64, pcm []byte, format SampleFormat, channels, channel int) []float64 {
+	if format == FormatS16LE {
+		return resample_split(ipcm, pcm, channels, channel)
+	}
+
+	size := format.BytesPerSample()
+	for i:=size*channel; i<len(pcm); i+=size*channels {
+		ipcm = append(ipcm, format.decode(pcm[i:i+size])*s16Scale)
+	}
+
+	return ipcm
+}
+
+// Split the channel of pcm, append to ipcm.
+func resample_split(ipcm []float64, pcm []byte, channels, channel int) []float64 {
 	for i:=2*channel; i<len(pcm); i+=2*channels {
 		// 16bits le sample
 		v := (int16(pcm[i])) | (int16(pcm[i + 1]) << 8)
-		ipcm = append(ipcm, v)
+		ipcm = append(ipcm, float64(v))
 	}
 
-	return
+	return ipcm
+}
+
+// The interpolator to resample the channel, for example, spline or sinc.
+type interpolator interface {
+	// The number of samples required before and after the position x,
+	// that is, the window is [floor(x)-before, floor(x)+after].
+	support() (before, after int)
+	// Interpolate the window w at position floor(x)+frac/den.
+	interpolate(w []float64, frac, den uint64) (float64, error)
+}
+
+// The 4-points cubic spline interpolator.
+type splineInterpolator struct {
+}
+
+func (v *splineInterpolator) support() (before, after int) {
+	return 0,3
+}
+
+func (v *splineInterpolator) interpolate(w []float64, frac, den uint64) (float64, error) {
+	return v.Interpolate(w, float64(frac)/float64(den))
+}
+
+func (v *splineInterpolator) Taps() (before, after int) {
+	return v.support()
+}
+
+func (v *splineInterpolator) Interpolate(w []float64, x float64) (float64, error) {
+	// Use arrays, never allocate.
+	xi := [4]float64{0, 1, 2, 3}
+	yi := [4]float64{w[0],w[1],w[2],w[3]}
+	xo := [1]float64{x}
+	yo := [1]float64{}
+	if err := spline(xi[:],yi[:],xo[:],yo[:]); err != nil {
+		return 0,err
+	}
+	return yo[0],nil
 }
 
 // xi must be [x0, x1, x2, x3] which is [1, 2, 3, 4]
@@ -220,16 +997,16 @@ func resampler_init_channel(pcm []byte, channels, channel int) (ipcm []int16) {
 // which will fill the yo with values.
 func spline(xi,yi,xo,yo []float64) (err error) {
 	if len(xi) != 4 {
-		return fmt.Errorf("invalid xi")
+		return &BufferSizeError{Name: "xi", Size: len(xi), Expect: 4}
 	}
 	if len(yi) != 4 {
-		return fmt.Errorf("invalid yi")
+		return &BufferSizeError{Name: "yi", Size: len(yi), Expect: 4}
 	}
 	if len(xo) == 0 {
-		return fmt.Errorf("invalid xo")
+		return &TooShortError{Name: "xo", Size: len(xo), Min: 1}
 	}
 	if len(yo) != len(xo) {
-		return fmt.Errorf("invalid yo")
+		return &BufferSizeError{Name: "yo", Size: len(yo), Expect: len(xo)}
 	}
 
 	x0,x1,x2,x3 := xi[0],xi[1],xi[2],xi[3]
@@ -0,0 +1,175 @@
+// The MIT License (MIT)
+//
+// Copyright (c) 2016 winlin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+
+// The PCM resample.
+package aresample
+
+import "math"
+
+// The resolution of sinc table, the number of points in each sample.
+const sincResolution = 512
+
+// The beta of Kaiser window, about 80dB attenuation of stopband.
+const sincKaiserBeta = 8.0
+
+// The windowed-sinc(Kaiser) band-limited interpolator,
+// which is a low-pass filter at the Nyquist of the lower rate.
+type sincInterpolator struct {
+	before int // The samples before the position.
+	after  int // The samples after the position.
+
+	fc    float64   // The normalized cutoff frequency, relative to the Nyquist of isr.
+	hw    float64   // The half width of kernel, in input samples.
+	beta  float64   // The beta of Kaiser window, see kaiser_beta.
+	table []float64 // The kernel h(t) for t in [0, hw], sincResolution points per sample.
+}
+
+// Create the sinc interpolator to resample from isr to osr.
+// The taps is the length of filter in samples, which is scaled by isr/osr when downsampling.
+// The cutoff is the normalized cutoff frequency in (0,1], relative to the Nyquist of the lower rate.
+func newSincInterpolator(isr, osr int, taps int, cutoff float64) (*sincInterpolator, error) {
+	return newSincKaiser(isr, osr, taps, cutoff, sincKaiserBeta)
+}
+
+// Create the sinc interpolator like newSincInterpolator, with the beta of Kaiser window.
+func newSincKaiser(isr, osr int, taps int, cutoff, beta float64) (*sincInterpolator, error) {
+	v,err := newSincWindowed(isr, osr, taps, cutoff, beta)
+	if err != nil {
+		return nil,err
+	}
+
+	v.build_table()
+	return v,nil
+}
+
+// Create the sinc interpolator like newSincKaiser, but without the lookup table,
+// which is only used to evaluate the kernel, for example, by the polyphase.
+func newSincWindowed(isr, osr int, taps int, cutoff, beta float64) (*sincInterpolator, error) {
+	if taps < 2 || taps > 1024 || (taps%2) != 0 {
+		return nil,&ParamError{Name: "taps", Value: taps, Err: ErrInvalidParameter}
+	}
+	if cutoff <= 0 || cutoff > 1 {
+		return nil,&ParamError{Name: "cutoff", Value: cutoff, Err: ErrInvalidParameter}
+	}
+
+	// When downsampling, lower the cutoff to the Nyquist of osr,
+	// and stretch the kernel to keep the taps in output samples.
+	scale := 1.0
+	if osr < isr {
+		scale = float64(osr) / float64(isr)
+	}
+	fc := cutoff * scale
+	hw := float64(taps) / 2 / scale
+
+	v := &sincInterpolator{fc: fc, hw: hw, beta: beta}
+	v.before = int(math.Ceil(hw)) - 1
+	v.after = int(math.Ceil(hw))
+
+	return v,nil
+}
+
+// Build the lookup table of kernel, which contains the last point h(hw)=0.
+func (v *sincInterpolator) build_table() {
+	v.table = make([]float64, int(math.Ceil(v.hw*sincResolution))+2)
+	for i := range v.table {
+		v.table[i] = v.kernel(float64(i) / sincResolution)
+	}
+}
+
+func (v *sincInterpolator) support() (before, after int) {
+	return v.before,v.after
+}
+
+func (v *sincInterpolator) interpolate(w []float64, frac, den uint64) (float64, error) {
+	return v.Interpolate(w, float64(frac) / float64(den))
+}
+
+func (v *sincInterpolator) Taps() (before, after int) {
+	return v.support()
+}
+
+func (v *sincInterpolator) Interpolate(w []float64, x float64) (float64, error) {
+	var y float64
+	for k, s := range w {
+		// The distance from sample w[k] to position.
+		t := math.Abs(x - float64(k-v.before))
+		y += s * v.lookup(t)
+	}
+	return y,nil
+}
+
+// The kernel h(t), where t is the distance in input samples.
+func (v *sincInterpolator) kernel(t float64) float64 {
+	return v.fc * sinc(v.fc*t) * kaiser(t/v.hw, v.beta)
+}
+
+// Lookup the kernel h(t) by linear interpolation of table.
+func (v *sincInterpolator) lookup(t float64) float64 {
+	if t >= v.hw {
+		return 0
+	}
+
+	p := t * sincResolution
+	i := int(p)
+	f := p - float64(i)
+	return v.table[i] + f*(v.table[i+1]-v.table[i])
+}
+
+// The normalized sinc, sin(pi*x)/(pi*x).
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	return math.Sin(math.Pi*x) / (math.Pi * x)
+}
+
+// The Kaiser window at r in [-1,1], which is zero outside.
+func kaiser(r, beta float64) float64 {
+	if r < -1 || r > 1 {
+		return 0
+	}
+	return bessel_i0(beta*math.Sqrt(1-r*r)) / bessel_i0(beta)
+}
+
+// The beta of Kaiser window for the attenuation of stopband in dB.
+func kaiser_beta(attenuation float64) float64 {
+	if attenuation > 50 {
+		return 0.1102 * (attenuation - 8.7)
+	}
+	if attenuation >= 21 {
+		return 0.5842*math.Pow(attenuation-21, 0.4) + 0.07886*(attenuation-21)
+	}
+	return 0
+}
+
+// The zeroth order modified Bessel function of the first kind.
+func bessel_i0(x float64) float64 {
+	sum, term := 1.0, 1.0
+	for k := 1; k < 64; k++ {
+		term *= (x / 2) / float64(k)
+		sum += term * term
+		if term*term < sum*1e-21 {
+			break
+		}
+	}
+	return sum
+}
@@ -0,0 +1,214 @@
+// The MIT License (MIT)
+//
+// Copyright (c) 2016 winlin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+// The PCM resample.
+package aresample
+
+import "math"
+
+// The mode to downmix stereo to mono.
+type Stereo2MonoMode int
+
+const (
+	// The average of L and R, (L+R)/2, never clip.
+	Stereo2MonoAverage Stereo2MonoMode = iota
+	// The energy-preserving sum, (L+R)*0.7071, the loudness of uncorrelated L and R is kept.
+	Stereo2MonoSum
+	// Use the L only.
+	Stereo2MonoLeft
+	// Use the R only.
+	Stereo2MonoRight
+	// The average of L and R, but use (L-R)/2 when the phase of R is inverted,
+	// which otherwise cancels to silence.
+	Stereo2MonoPhaseAware
+)
+
+// The correlation of L and R below which the stereo is considered as inverted-phase.
+const stereoInvertedCorrelation = -0.5
+
+// The correlation of L and R above which the inverted-phase stereo is restored, the hysteresis
+// of Stereo2Mono, which never flips when the correlation is about stereoInvertedCorrelation.
+const stereoRestoredCorrelation = -0.3
+
+// The frames of the correlation of Stereo2Mono, the weight of older frames decays by e.
+const stereoCorrelationFrames = 8192
+
+// Transform the stereo pcm to mono npcm by mode, where len(npcm)===len(pcm)/2.
+// @remark the pcm must be s16le(16bits PCM in little-endian).
+// @remark the Stereo2MonoPhaseAware detects the inversion of each pcm, so the output of
+// short chunks of stream may flip between (L+R)/2 and (L-R)/2, use Stereo2Mono for stream.
+func PcmS16leStereo2Mono(pcm, npcm []byte, mode Stereo2MonoMode) (err error) {
+	if err = stereo2mono_validate(pcm, npcm, mode); err != nil {
+		return
+	}
+
+	var inverted bool
+	if mode == Stereo2MonoPhaseAware {
+		if inverted,err = PcmS16leStereoInverted(pcm); err != nil {
+			return
+		}
+	}
+
+	stereo2mono(pcm, npcm, mode, inverted)
+	return
+}
+
+// The stateful downmixer of stereo to mono for stream, which holds the inversion of
+// Stereo2MonoPhaseAware across pcm, by the correlation of recent frames with hysteresis.
+type Stereo2Mono struct {
+	mode Stereo2MonoMode
+	// The decayed sums of L*R, L*L and R*R of recent frames.
+	lr, ll, rr float64
+	inverted   bool
+}
+
+// Create the downmixer of stereo to mono by mode.
+func NewStereo2Mono(mode Stereo2MonoMode) (*Stereo2Mono, error) {
+	if mode < Stereo2MonoAverage || mode > Stereo2MonoPhaseAware {
+		return nil,&ParamError{Name: "mode", Value: mode, Err: ErrInvalidParameter}
+	}
+	return &Stereo2Mono{mode: mode},nil
+}
+
+// Whether the stereo is inverted-phase, which is downmixed by (L-R)/2.
+func (v *Stereo2Mono) Inverted() bool {
+	return v.inverted
+}
+
+// Reset the correlation and inversion, for example, for a new stream.
+func (v *Stereo2Mono) Reset() {
+	v.lr,v.ll,v.rr,v.inverted = 0,0,0,false
+}
+
+// Transform the stereo pcm to mono npcm, where len(npcm)===len(pcm)/2.
+// @remark the pcm must be s16le(16bits PCM in little-endian).
+func (v *Stereo2Mono) DownmixPcmS16le(pcm, npcm []byte) (err error) {
+	if err = stereo2mono_validate(pcm, npcm, v.mode); err != nil {
+		return
+	}
+
+	if v.mode == Stereo2MonoPhaseAware {
+		lr,ll,rr := stereo_sums(pcm)
+		d := math.Exp(-float64(len(pcm)/4) / stereoCorrelationFrames)
+		v.lr,v.ll,v.rr = v.lr*d + lr,v.ll*d + ll,v.rr*d + rr
+
+		// Keep the inversion until the correlation crosses the other threshold.
+		if c := stereo_correlation(v.lr, v.ll, v.rr); v.inverted {
+			v.inverted = c < stereoRestoredCorrelation
+		} else {
+			v.inverted = c < stereoInvertedCorrelation
+		}
+	}
+
+	stereo2mono(pcm, npcm, v.mode, v.inverted)
+	return
+}
+
+func stereo2mono_validate(pcm, npcm []byte, mode Stereo2MonoMode) error {
+	if (len(pcm) % 4) != 0 {
+		return &UnalignedError{Name: "PCM", Size: len(pcm), Align: 4}
+	}
+	if len(npcm) != len(pcm)/2 {
+		return &BufferSizeError{Name: "NPCM", Size: len(npcm), Expect: len(pcm)/2}
+	}
+	if mode < Stereo2MonoAverage || mode > Stereo2MonoPhaseAware {
+		return &ParamError{Name: "mode", Value: mode, Err: ErrInvalidParameter}
+	}
+	return nil
+}
+
+// Downmix the stereo pcm to mono npcm by mode, use (L-R)/2 if phase-aware and inverted.
+func stereo2mono(pcm, npcm []byte, mode Stereo2MonoMode, inverted bool) {
+	// The gain of L and R.
+	l,r := float32(0.5),float32(0.5)
+	switch mode {
+	case Stereo2MonoSum:
+		l,r = 0.7071,0.7071
+	case Stereo2MonoLeft:
+		l,r = 1,0
+	case Stereo2MonoRight:
+		l,r = 0,1
+	case Stereo2MonoPhaseAware:
+		if inverted {
+			r = -0.5
+		}
+	}
+
+	for i:=0; i<len(pcm); i+=4 {
+		// 16bits le sample of L and R
+		vl := (int16(pcm[i])) | (int16(pcm[i+1]) << 8)
+		vr := (int16(pcm[i+2])) | (int16(pcm[i+3]) << 8)
+
+		// The sum may exceed the int16, for example, the (L+R)*0.7071 or the (L-R)/2.
+		f := float32(vl) * l + float32(vr) * r
+		if f > math.MaxInt16 {
+			f = math.MaxInt16
+		} else if f < math.MinInt16 {
+			f = math.MinInt16
+		}
+		v := int16(f)
+
+		npcm[i/2] = byte(v)
+		npcm[i/2 + 1] = byte(v >> 8)
+	}
+}
+
+// The correlation of L and R in [-1,1] of the stereo pcm, where 1 is mono-compatible,
+// 0 is uncorrelated and -1 is inverted-phase, which cancels to silence when mixed.
+// @remark the pcm must be s16le(16bits PCM in little-endian).
+func PcmS16leStereoCorrelation(pcm []byte) (c float64, err error) {
+	if (len(pcm) % 4) != 0 {
+		return 0,&UnalignedError{Name: "PCM", Size: len(pcm), Align: 4}
+	}
+
+	return stereo_correlation(stereo_sums(pcm)),nil
+}
+
+// The sums of L*R, L*L and R*R of the stereo pcm.
+func stereo_sums(pcm []byte) (lr, ll, rr float64) {
+	for i:=0; i<len(pcm); i+=4 {
+		vl := float64((int16(pcm[i])) | (int16(pcm[i+1]) << 8))
+		vr := float64((int16(pcm[i+2])) | (int16(pcm[i+3]) << 8))
+		lr += vl * vr
+		ll += vl * vl
+		rr += vr * vr
+	}
+	return
+}
+
+func stereo_correlation(lr, ll, rr float64) float64 {
+	// The silent channel is uncorrelated.
+	if ll == 0 || rr == 0 {
+		return 0
+	}
+	return lr / math.Sqrt(ll * rr)
+}
+
+// Whether the stereo pcm is inverted-phase, that is, the R is about the -L,
+// which cancels to silence when mixed to mono by average.
+// @remark the pcm must be s16le(16bits PCM in little-endian).
+func PcmS16leStereoInverted(pcm []byte) (inverted bool, err error) {
+	var c float64
+	if c,err = PcmS16leStereoCorrelation(pcm); err != nil {
+		return
+	}
+	return c < stereoInvertedCorrelation,nil
+}
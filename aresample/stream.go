@@ -0,0 +1,167 @@
+// The MIT License (MIT)
+//
+// Copyright (c) 2016 winlin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+
+// The PCM resample.
+package aresample
+
+import "io"
+
+// The frames to read from the underlayer reader each time.
+const streamReadFrames = 4096
+
+// The stream of resampler, which buffers the partial frames of pcm.
+type srStream struct {
+	r       ResampleSampleRate
+	frame   int    // The bytes of input frame, all channels of a sample.
+	pending []byte // The partial frame not resampled.
+	spare   []byte // The buffer to swap with pending, because the bypass npcm is the pcm.
+}
+
+func newStream(cfg Config) (*srStream, error) {
+	r,err := NewResampler(cfg)
+	if err != nil {
+		return nil,err
+	}
+
+	format,_ := cfg.formats()
+	return &srStream{r: r, frame: format.BytesPerSample() * cfg.Channels},nil
+}
+
+// Resample the pcm, which may contains partial frames, return the resampled npcm.
+func (v *srStream) write(pcm []byte) (npcm []byte, err error) {
+	v.pending = append(v.pending, pcm...)
+
+	// Resample the whole frames, keep the partial frame.
+	nb := len(v.pending) / v.frame
+	pcm = v.pending[:nb*v.frame]
+	if npcm,err = v.r.Resample(pcm); err != nil {
+		return nil,err
+	}
+	v.pending,v.spare = append(v.spare[:0], v.pending[nb*v.frame:]...),pcm[:0]
+
+	return
+}
+
+// Flush the cache of resampler, the total output is exactly ceil(nbInput*osr/isr) frames.
+func (v *srStream) flush() (npcm []byte, err error) {
+	if len(v.pending) > 0 {
+		return nil,&UnalignedError{Name: "pcm", Size: len(v.pending), Align: v.frame}
+	}
+	return v.r.Flush()
+}
+
+// The writer to resample the pcm written to the underlayer writer.
+type Writer struct {
+	w      io.Writer
+	s      *srStream
+	closed bool
+}
+
+// Create a writer, which resamples the pcm written by cfg and writes to w,
+// the pcm can be written in any size, the partial frame is buffered,
+// and the cached samples are flushed when Close.
+// @remark the w is not closed when Close.
+func NewWriter(w io.Writer, cfg Config) (*Writer, error) {
+	s,err := newStream(cfg)
+	if err != nil {
+		return nil,err
+	}
+	return &Writer{w: w, s: s},nil
+}
+
+func (v *Writer) Write(p []byte) (n int, err error) {
+	if v.closed {
+		return 0,ErrClosed
+	}
+
+	var npcm []byte
+	if npcm,err = v.s.write(p); err != nil {
+		return 0,err
+	}
+	if _,err = v.w.Write(npcm); err != nil {
+		return 0,err
+	}
+	return len(p),nil
+}
+
+// Flush the buffered pcm and the cached samples of resampler to the underlayer writer.
+func (v *Writer) Close() (err error) {
+	if v.closed {
+		return nil
+	}
+	v.closed = true
+
+	var npcm []byte
+	if npcm,err = v.s.flush(); err != nil {
+		return err
+	}
+	_,err = v.w.Write(npcm)
+	return
+}
+
+// The reader to resample the pcm read from the underlayer reader.
+type Reader struct {
+	r      io.Reader
+	s      *srStream
+	buf    []byte // The buffer to read from underlayer reader.
+	npcm   []byte // The resampled pcm not read.
+	err    error  // The error of underlayer reader, io.EOF when finished.
+}
+
+// Create a reader, which reads the pcm from r and resamples by cfg,
+// the cached samples are flushed when r returns io.EOF.
+func NewReader(r io.Reader, cfg Config) (*Reader, error) {
+	s,err := newStream(cfg)
+	if err != nil {
+		return nil,err
+	}
+	return &Reader{r: r, s: s, buf: make([]byte, streamReadFrames*s.frame)},nil
+}
+
+func (v *Reader) Read(p []byte) (n int, err error) {
+	for len(v.npcm) == 0 {
+		if v.err != nil {
+			return 0,v.err
+		}
+
+		var nn int
+		nn,v.err = v.r.Read(v.buf)
+		if v.npcm,err = v.s.write(v.buf[:nn]); err != nil {
+			v.err = err
+			return 0,err
+		}
+
+		// Flush the cache when the underlayer reader is finished.
+		if v.err == io.EOF {
+			var tail []byte
+			if tail,err = v.s.flush(); err != nil {
+				v.err = err
+				return 0,err
+			}
+			v.npcm = append(v.npcm, tail...)
+		}
+	}
+
+	n = copy(p, v.npcm)
+	v.npcm = v.npcm[n:]
+	return
+}
@@ -0,0 +1,151 @@
+// The MIT License (MIT)
+//
+// Copyright (c) 2016 winlin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+
+// The WAV file reader and writer.
+package wav
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// The reader of WAV file, which parses the header, and reads the samples of data chunk.
+type Reader struct {
+	Header
+	r      io.Reader
+	size   int64 // The size of data chunk, -1 if unknown, for example, the live stream.
+	remain int64 // The bytes left in data chunk, -1 if unknown.
+}
+
+// Create the reader, which parses the RIFF or RF64 header to the data chunk,
+// and skips other chunks, for example, LIST or JUNK.
+func NewReader(r io.Reader) (*Reader, error) {
+	var b [12]byte
+	if _,err := io.ReadFull(r, b[:]); err != nil {
+		return nil,err
+	}
+
+	id := string(b[0:4])
+	if (id != "RIFF" && id != "RF64") || string(b[8:12]) != "WAVE" {
+		return nil,&HeaderError{Name: "riff", Value: string(b[0:4]) + string(b[8:12]), Err: ErrInvalidHeader}
+	}
+
+	v := &Reader{r: r}
+	v.RF64 = id == "RF64"
+
+	var fmtParsed bool
+	var dataSize uint64 // The size of data in ds64 chunk.
+	for {
+		if _,err := io.ReadFull(r, b[:8]); err != nil {
+			if err == io.EOF {
+				return nil,&HeaderError{Name: "data", Value: "none", Err: ErrInvalidHeader}
+			}
+			return nil,err
+		}
+		id,size := string(b[0:4]),int64(binary.LittleEndian.Uint32(b[4:8]))
+
+		switch id {
+		case "ds64", "fmt ":
+			if size > 4096 {
+				return nil,&HeaderError{Name: id + " size", Value: size, Err: ErrInvalidHeader}
+			}
+			body := make([]byte, size + size%2)
+			if _,err := io.ReadFull(r, body); err != nil {
+				return nil,err
+			}
+
+			if id == "fmt " {
+				if err := v.unmarshal(body[:size]); err != nil {
+					return nil,err
+				}
+				fmtParsed = true
+			} else if v.RF64 {
+				if size < 28 {
+					return nil,&HeaderError{Name: "ds64 size", Value: size, Err: ErrInvalidHeader}
+				}
+				dataSize = binary.LittleEndian.Uint64(body[8:])
+			}
+		case "data":
+			if !fmtParsed {
+				return nil,&HeaderError{Name: "fmt", Value: "none", Err: ErrInvalidHeader}
+			}
+
+			// The size is in ds64 for RF64, or unknown for live stream.
+			if size == riffMaxSize && v.RF64 {
+				size = int64(dataSize)
+			} else if size == riffMaxSize {
+				size = -1
+			}
+			if size >= 0 {
+				size = size / int64(v.BlockAlign()) * int64(v.BlockAlign())
+			}
+			v.size,v.remain = size,size
+			return v,nil
+		default:
+			if _,err := io.CopyN(io.Discard, r, size + size%2); err != nil {
+				return nil,err
+			}
+		}
+	}
+}
+
+// The size of data chunk in bytes, -1 if unknown, for example, the live stream.
+func (v *Reader) Size() int64 {
+	return v.size
+}
+
+// The number of frames, -1 if unknown.
+func (v *Reader) Frames() int64 {
+	if v.size < 0 {
+		return -1
+	}
+	return v.size / int64(v.BlockAlign())
+}
+
+// Read the samples of data chunk in whole frames, so len(p) must be at least BlockAlign,
+// return io.EOF at the end of data, and a truncated file ends at the last whole frame.
+func (v *Reader) Read(p []byte) (n int, err error) {
+	block := v.BlockAlign()
+	if v.remain == 0 {
+		return 0,io.EOF
+	}
+	if len(p) < block {
+		return 0,io.ErrShortBuffer
+	}
+
+	size := int64(len(p) / block * block)
+	if v.remain > 0 && size > v.remain {
+		size = v.remain
+	}
+
+	n,err = io.ReadFull(v.r, p[:size])
+	n = n / block * block
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		v.remain,err = 0,nil
+	} else if v.remain > 0 {
+		v.remain -= int64(n)
+	}
+	if n == 0 && err == nil {
+		err = io.EOF
+	}
+	return
+}
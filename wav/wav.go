@@ -0,0 +1,269 @@
+// The MIT License (MIT)
+//
+// Copyright (c) 2016 winlin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+
+// The WAV file reader and writer.
+package wav
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/bits"
+	"github.com/winlinvip/go-aresample/aresample"
+)
+
+// The errors of WAV file, use errors.Is to check the error, and errors.As
+// to get the detail, for example, the *HeaderError.
+var (
+	ErrInvalidHeader = errors.New("invalid wav header")
+	ErrUnsupported   = errors.New("unsupported wav format")
+)
+
+// The invalid or unsupported field of header, for example, the format tag or bits.
+type HeaderError struct {
+	Name  string      // The name of field.
+	Value interface{} // The invalid value.
+	Err   error       // The kind of error, for example, ErrUnsupported.
+}
+
+func (v *HeaderError) Error() string {
+	return fmt.Sprintf("%v %v=%v", v.Err, v.Name, v.Value)
+}
+
+func (v *HeaderError) Unwrap() error {
+	return v.Err
+}
+
+// The format tag of WAVE, or the sub format of extensible.
+type Format uint16
+
+const (
+	FormatPCM        Format = 0x0001
+	FormatIEEEFloat  Format = 0x0003
+	FormatExtensible Format = 0xFFFE
+)
+
+func (v Format) String() string {
+	switch v {
+	case FormatPCM:
+		return "pcm"
+	case FormatIEEEFloat:
+		return "float"
+	case FormatExtensible:
+		return "extensible"
+	}
+	return fmt.Sprintf("unknown(%#x)", uint16(v))
+}
+
+// The max size of RIFF chunk, use RF64 for larger file.
+const riffMaxSize = 0xFFFFFFFF
+
+// The GUID of sub format is {XXXXXXXX-0000-0010-8000-00AA00389B71}, where XXXXXXXX is the format tag.
+var guidSuffix = [14]byte{0x00, 0x00, 0x00, 0x00, 0x10, 0x00, 0x80, 0x00, 0x00, 0xAA, 0x00, 0x38, 0x9B, 0x71}
+
+// The format header of WAV, that is the fmt chunk, which configures the resampler by Config.
+type Header struct {
+	Format        Format // The PCM or IEEE float, which is the sub format for extensible.
+	Extensible    bool   // Whether WAVE_FORMAT_EXTENSIBLE, with the valid bits and channel mask.
+	Channels      int    // The number of channels.
+	SampleRate    int    // The sample rate in Hz.
+	BitsPerSample int    // The bits of container of sample, 8, 16, 24, 32 or 64.
+	ValidBits     int    // The valid bits in the container, 0 for all bits.
+	ChannelMask   uint32 // The speakers of channels, 0 for the default layout, see aresample.ChannelLayout.
+	RF64          bool   // Whether RF64 for more than 4GB, the writer upgrades automatically.
+}
+
+// Create the header for the sample format, channels and sample rate, which uses the
+// extensible format with the default channel mask for more than 2 channels.
+func NewHeader(format aresample.SampleFormat, channels, sampleRate int) (h Header, err error) {
+	h = Header{Format: FormatPCM, Channels: channels, SampleRate: sampleRate, BitsPerSample: format.Bits}
+	if format.Float {
+		h.Format = FormatIEEEFloat
+	}
+	if channels > 2 {
+		h.Extensible,h.ChannelMask = true,uint32(aresample.LayoutOf(channels))
+	}
+
+	if err = h.Validate(); err != nil {
+		return
+	}
+
+	// The format must be the same, for example, the 24bits must be packed little-endian.
+	if f,err := h.SampleFormat(); err != nil || f != format {
+		return h,&HeaderError{Name: "sample format", Value: format, Err: ErrUnsupported}
+	}
+	return
+}
+
+// Validate the header.
+func (v *Header) Validate() error {
+	if v.Format != FormatPCM && v.Format != FormatIEEEFloat {
+		return &HeaderError{Name: "format", Value: v.Format, Err: ErrUnsupported}
+	}
+	if v.Channels < 1 || v.Channels > 0xFFFF {
+		return &HeaderError{Name: "channels", Value: v.Channels, Err: ErrInvalidHeader}
+	}
+	if v.SampleRate <= 0 || int64(v.SampleRate) > 0xFFFFFFFF {
+		return &HeaderError{Name: "sample rate", Value: v.SampleRate, Err: ErrInvalidHeader}
+	}
+	if v.ValidBits < 0 || v.ValidBits > v.BitsPerSample {
+		return &HeaderError{Name: "valid bits", Value: v.ValidBits, Err: ErrInvalidHeader}
+	}
+	if v.ChannelMask != 0 && v.Channels != bits.OnesCount32(v.ChannelMask) {
+		return &HeaderError{Name: "channel mask", Value: fmt.Sprintf("%#x", v.ChannelMask), Err: ErrInvalidHeader}
+	}
+	if _,err := v.SampleFormat(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// The size of frame in bytes, that is the nBlockAlign.
+func (v *Header) BlockAlign() int {
+	return v.Channels * v.BitsPerSample / 8
+}
+
+// The sample format of container, for example, the 20bits in 24bits is FormatS24LE,
+// because the valid bits are the most significant bits.
+func (v *Header) SampleFormat() (f aresample.SampleFormat, err error) {
+	switch {
+	case v.Format == FormatIEEEFloat && v.BitsPerSample == 32:
+		return aresample.FormatF32LE,nil
+	case v.Format == FormatIEEEFloat && v.BitsPerSample == 64:
+		return aresample.FormatF64LE,nil
+	case v.Format == FormatPCM && v.BitsPerSample == 8:
+		return aresample.FormatU8,nil
+	case v.Format == FormatPCM && v.BitsPerSample == 16:
+		return aresample.FormatS16LE,nil
+	case v.Format == FormatPCM && v.BitsPerSample == 24:
+		return aresample.FormatS24LE,nil
+	case v.Format == FormatPCM && v.BitsPerSample == 32:
+		return aresample.FormatS32LE,nil
+	}
+	return f,&HeaderError{Name: "bits", Value: fmt.Sprintf("%v %v", v.Format, v.BitsPerSample), Err: ErrUnsupported}
+}
+
+// The channel layout, which is the channel mask, or the default layout of channels.
+func (v *Header) Layout() (aresample.ChannelLayout, error) {
+	l := aresample.ChannelLayout(v.ChannelMask)
+	if l == 0 {
+		l = aresample.LayoutOf(v.Channels)
+	}
+	if err := l.Validate(); err != nil {
+		return 0,err
+	}
+	return l,nil
+}
+
+// The config of resampler from this header to nSampleRate, in the same sample format.
+func (v *Header) Config(nSampleRate int) (cfg aresample.Config, err error) {
+	format,err := v.SampleFormat()
+	if err != nil {
+		return
+	}
+
+	cfg = aresample.Config{
+		Channels: v.Channels, SampleRate: v.SampleRate, NSampleRate: nSampleRate,
+		Format: format, NFormat: format,
+	}
+	return
+}
+
+// The size of fmt chunk.
+func (v *Header) fmtSize() int {
+	if v.Extensible {
+		return 40
+	}
+	if v.Format != FormatPCM {
+		return 18
+	}
+	return 16
+}
+
+// Marshal the fmt chunk to b, which is at least fmtSize bytes.
+func (v *Header) marshal(b []byte) {
+	tag := v.Format
+	if v.Extensible {
+		tag = FormatExtensible
+	}
+	binary.LittleEndian.PutUint16(b[0:], uint16(tag))
+	binary.LittleEndian.PutUint16(b[2:], uint16(v.Channels))
+	binary.LittleEndian.PutUint32(b[4:], uint32(v.SampleRate))
+	binary.LittleEndian.PutUint32(b[8:], uint32(v.SampleRate * v.BlockAlign()))
+	binary.LittleEndian.PutUint16(b[12:], uint16(v.BlockAlign()))
+	binary.LittleEndian.PutUint16(b[14:], uint16(v.BitsPerSample))
+	if v.fmtSize() == 16 {
+		return
+	}
+
+	binary.LittleEndian.PutUint16(b[16:], uint16(v.fmtSize() - 18))
+	if !v.Extensible {
+		return
+	}
+
+	valid := v.ValidBits
+	if valid == 0 {
+		valid = v.BitsPerSample
+	}
+	binary.LittleEndian.PutUint16(b[18:], uint16(valid))
+	binary.LittleEndian.PutUint32(b[20:], v.ChannelMask)
+	binary.LittleEndian.PutUint16(b[24:], uint16(v.Format))
+	copy(b[26:], guidSuffix[:])
+}
+
+// Unmarshal the fmt chunk.
+func (v *Header) unmarshal(b []byte) error {
+	if len(b) < 16 {
+		return &HeaderError{Name: "fmt size", Value: len(b), Err: ErrInvalidHeader}
+	}
+
+	v.Format = Format(binary.LittleEndian.Uint16(b[0:]))
+	v.Channels = int(binary.LittleEndian.Uint16(b[2:]))
+	v.SampleRate = int(binary.LittleEndian.Uint32(b[4:]))
+	blockAlign := int(binary.LittleEndian.Uint16(b[12:]))
+	validBits := int(binary.LittleEndian.Uint16(b[14:]))
+
+	if v.Format == FormatExtensible {
+		if len(b) < 40 || binary.LittleEndian.Uint16(b[16:]) < 22 {
+			return &HeaderError{Name: "extensible size", Value: len(b), Err: ErrInvalidHeader}
+		}
+		if !bytes.Equal(b[26:40], guidSuffix[:]) {
+			return &HeaderError{Name: "sub format", Value: fmt.Sprintf("%x", b[24:40]), Err: ErrUnsupported}
+		}
+		v.Extensible = true
+		validBits = int(binary.LittleEndian.Uint16(b[18:]))
+		v.ChannelMask = binary.LittleEndian.Uint32(b[20:])
+		v.Format = Format(binary.LittleEndian.Uint16(b[24:]))
+	}
+
+	// The container is the block align, and the bits is the valid bits, for example, 20bits in 24bits.
+	if v.Channels == 0 || blockAlign%v.Channels != 0 {
+		return &HeaderError{Name: "block align", Value: blockAlign, Err: ErrInvalidHeader}
+	}
+	v.BitsPerSample = blockAlign / v.Channels * 8
+	if validBits != v.BitsPerSample {
+		v.ValidBits = validBits
+	}
+
+	return v.Validate()
+}
@@ -0,0 +1,355 @@
+// The MIT License (MIT)
+//
+// Copyright (c) 2016 winlin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+
+// The WAV file reader and writer.
+package wav
+
+import (
+	"testing"
+	"bytes"
+	"errors"
+	"io"
+	"github.com/winlinvip/go-aresample/aresample"
+)
+
+// The in-memory io.WriteSeeker, which only keeps the first limit bytes when limit is not 0,
+// to write the huge file for RF64.
+type seekBuffer struct {
+	b     []byte
+	pos   int64
+	size  int64
+	limit int64
+}
+
+func (v *seekBuffer) Write(p []byte) (n int, err error) {
+	end := v.pos + int64(len(p))
+	if v.limit > 0 && end > v.limit {
+		end = v.limit
+	}
+	for off := v.pos; off < end; off++ {
+		for int64(len(v.b)) <= off {
+			v.b = append(v.b, 0)
+		}
+		v.b[off] = p[off-v.pos]
+	}
+	v.pos += int64(len(p))
+	if v.pos > v.size {
+		v.size = v.pos
+	}
+	return len(p),nil
+}
+
+func (v *seekBuffer) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+		v.pos = offset
+	case io.SeekCurrent:
+		v.pos += offset
+	case io.SeekEnd:
+		v.pos = v.size + offset
+	}
+	return v.pos,nil
+}
+
+// The canonical 16bits stereo 8000Hz wav, with a LIST chunk of odd size before data.
+var canonical = []byte{
+	'R', 'I', 'F', 'F', 0x3a, 0x00, 0x00, 0x00, 'W', 'A', 'V', 'E',
+	'f', 'm', 't', ' ', 0x10, 0x00, 0x00, 0x00,
+	0x01, 0x00, 0x02, 0x00, 0x40, 0x1f, 0x00, 0x00, 0x00, 0x7d, 0x00, 0x00, 0x04, 0x00, 0x10, 0x00,
+	'L', 'I', 'S', 'T', 0x03, 0x00, 0x00, 0x00, 'a', 'b', 'c', 0x00,
+	'd', 'a', 't', 'a', 0x0a, 0x00, 0x00, 0x00,
+	0x01, 0x00, 0x02, 0x00, 0x03, 0x00, 0x04, 0x00, 0xff, 0xff,
+}
+
+func TestReader(t *testing.T) {
+	r,err := NewReader(bytes.NewReader(canonical))
+	if err != nil {
+		t.Error("parse failed, err is", err)
+		return
+	}
+	if r.Format != FormatPCM || r.Extensible || r.RF64 || r.Channels != 2 || r.SampleRate != 8000 || r.BitsPerSample != 16 || r.ValidBits != 0 {
+		t.Error("invalid header", r.Header)
+	}
+	if r.BlockAlign() != 4 || r.Size() != 8 || r.Frames() != 2 {
+		t.Error("invalid size", r.BlockAlign(), r.Size(), r.Frames())
+	}
+	if l,err := r.Layout(); err != nil || l != aresample.LayoutStereo {
+		t.Error("invalid layout", l, err)
+	}
+
+	// Read in whole frames, and the partial frame is ignored.
+	if _,err := r.Read(make([]byte, 3)); err != io.ErrShortBuffer {
+		t.Error("invalid read", err)
+	}
+	p := make([]byte, 7)
+	if n,err := r.Read(p); n != 4 || err != nil || !bytes.Equal(p[:4], []byte{1, 0, 2, 0}) {
+		t.Error("invalid read", n, err)
+	}
+	if n,err := r.Read(p); n != 4 || err != nil || !bytes.Equal(p[:4], []byte{3, 0, 4, 0}) {
+		t.Error("invalid read", n, err)
+	}
+	if n,err := r.Read(p); n != 0 || err != io.EOF {
+		t.Error("invalid read", n, err)
+	}
+
+	// The truncated file ends at the last whole frame.
+	r,_ = NewReader(bytes.NewReader(canonical[:len(canonical)-5]))
+	if b,err := io.ReadAll(r); err != nil || !bytes.Equal(b, []byte{1, 0, 2, 0}) {
+		t.Error("invalid truncated", b, err)
+	}
+
+	// The unknown size of live stream, reads to EOF.
+	live := append([]byte{}, canonical...)
+	copy(live[52:56], []byte{0xff, 0xff, 0xff, 0xff})
+	if r,err = NewReader(bytes.NewReader(live)); err != nil || r.Size() != -1 || r.Frames() != -1 {
+		t.Error("invalid live", err)
+	} else if b,err := io.ReadAll(r); err != nil || len(b) != 8 {
+		t.Error("invalid live", b, err)
+	}
+
+	for _,v := range []struct{
+		b []byte
+		err error
+	}{
+		{append([]byte("RIFX"), canonical[4:]...), ErrInvalidHeader},
+		{canonical[:36], ErrInvalidHeader},
+		{append(append([]byte{}, canonical[:12]...), canonical[48:]...), ErrInvalidHeader},
+		{append(append(append([]byte{}, canonical[:20]...), 0x55, 0x00), canonical[22:]...), ErrUnsupported},
+		{append(append(append([]byte{}, canonical[:32]...), 0x0a, 0x00), canonical[34:]...), ErrUnsupported},
+		{append(append(append([]byte{}, canonical[:34]...), 0x18, 0x00), canonical[36:]...), ErrInvalidHeader},
+		{append(append(append([]byte{}, canonical[:32]...), 0x03, 0x00), canonical[34:]...), ErrInvalidHeader},
+	} {
+		if _,err := NewReader(bytes.NewReader(v.b)); !errors.Is(err, v.err) {
+			t.Error("invalid error", err, v.err)
+		}
+	}
+	if _,err := NewReader(bytes.NewReader(canonical[:8])); err != io.ErrUnexpectedEOF {
+		t.Error("invalid error", err)
+	}
+}
+
+func TestExtensible(t *testing.T) {
+	// The 5.1 of 20bits in 24bits container.
+	b := []byte{
+		'R', 'I', 'F', 'F', 0x00, 0x00, 0x00, 0x00, 'W', 'A', 'V', 'E',
+		'f', 'm', 't', ' ', 0x28, 0x00, 0x00, 0x00,
+		0xfe, 0xff, 0x06, 0x00, 0x80, 0xbb, 0x00, 0x00, 0x00, 0xca, 0x08, 0x00, 0x12, 0x00, 0x18, 0x00,
+		0x16, 0x00, 0x14, 0x00, 0x3f, 0x00, 0x00, 0x00,
+		0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x10, 0x00, 0x80, 0x00, 0x00, 0xaa, 0x00, 0x38, 0x9b, 0x71,
+		'd', 'a', 't', 'a', 0x12, 0x00, 0x00, 0x00,
+	}
+	b = append(b, make([]byte, 18)...)
+
+	r,err := NewReader(bytes.NewReader(b))
+	if err != nil {
+		t.Error("parse failed, err is", err)
+		return
+	}
+	if r.Format != FormatPCM || !r.Extensible || r.Channels != 6 || r.SampleRate != 48000 || r.BitsPerSample != 24 || r.ValidBits != 20 || r.ChannelMask != 0x3f {
+		t.Error("invalid header", r.Header)
+	}
+	if l,err := r.Layout(); err != nil || l != aresample.Layout5Point1 {
+		t.Error("invalid layout", l, err)
+	}
+	if f,err := r.SampleFormat(); err != nil || f != aresample.FormatS24LE {
+		t.Error("invalid format", f, err)
+	}
+	if r.Frames() != 1 {
+		t.Error("invalid frames", r.Frames())
+	}
+
+	// The mask must match the channels, and the sub format must be PCM or float.
+	b[40] = 0x0f
+	if _,err := NewReader(bytes.NewReader(b)); !errors.Is(err, ErrInvalidHeader) {
+		t.Error("invalid mask", err)
+	}
+	b[40],b[44] = 0x3f,0x02
+	if _,err := NewReader(bytes.NewReader(b)); !errors.Is(err, ErrUnsupported) {
+		t.Error("invalid sub format", err)
+	}
+	b[44],b[50] = 0x01,0x11
+	if _,err := NewReader(bytes.NewReader(b)); !errors.Is(err, ErrUnsupported) {
+		t.Error("invalid sub format", err)
+	}
+}
+
+func TestHeader(t *testing.T) {
+	for _,v := range []struct{
+		format aresample.SampleFormat
+		channels int
+		tag Format
+		extensible bool
+		mask uint32
+		fmtSize int
+	}{
+		{aresample.FormatU8, 1, FormatPCM, false, 0, 16},
+		{aresample.FormatS16LE, 2, FormatPCM, false, 0, 16},
+		{aresample.FormatS24LE, 2, FormatPCM, false, 0, 16},
+		{aresample.FormatS32LE, 1, FormatPCM, false, 0, 16},
+		{aresample.FormatF32LE, 2, FormatIEEEFloat, false, 0, 18},
+		{aresample.FormatF64LE, 1, FormatIEEEFloat, false, 0, 18},
+		{aresample.FormatS16LE, 6, FormatPCM, true, 0x3f, 40},
+		{aresample.FormatF32LE, 8, FormatIEEEFloat, true, 0x63f, 40},
+		{aresample.FormatS16LE, 5, FormatPCM, true, 0, 40},
+	} {
+		h,err := NewHeader(v.format, v.channels, 44100)
+		if err != nil {
+			t.Error("invalid header, err is", err)
+			continue
+		}
+		if h.Format != v.tag || h.Extensible != v.extensible || h.ChannelMask != v.mask || h.fmtSize() != v.fmtSize {
+			t.Error("invalid header", h, v)
+		}
+
+		cfg,err := h.Config(48000)
+		if err != nil || cfg.Channels != v.channels || cfg.SampleRate != 44100 || cfg.NSampleRate != 48000 || cfg.Format != v.format || cfg.NFormat != v.format {
+			t.Error("invalid config", cfg, err)
+		}
+	}
+
+	for _,f := range []aresample.SampleFormat{aresample.FormatS16BE, aresample.FormatS8, aresample.FormatU16LE, aresample.FormatS24In32LE} {
+		if _,err := NewHeader(f, 2, 44100); !errors.Is(err, ErrUnsupported) {
+			t.Error("invalid format", f, err)
+		}
+	}
+	if _,err := NewHeader(aresample.FormatS16LE, 0, 44100); !errors.Is(err, ErrInvalidHeader) {
+		t.Error("invalid channels", err)
+	}
+	if _,err := NewHeader(aresample.FormatS16LE, 2, 0); !errors.Is(err, ErrInvalidHeader) {
+		t.Error("invalid sample rate", err)
+	}
+	if v := FormatExtensible.String(); v != "extensible" {
+		t.Error("invalid format", v)
+	}
+}
+
+func TestWriter(t *testing.T) {
+	for _,f := range []aresample.SampleFormat{aresample.FormatU8, aresample.FormatS16LE, aresample.FormatS24LE, aresample.FormatF32LE} {
+		for _,channels := range []int{1, 2, 6} {
+			for _,rf64 := range []bool{false, true} {
+				h,err := NewHeader(f, channels, 44100)
+				if err != nil {
+					t.Error("invalid header, err is", err)
+					return
+				}
+				h.RF64 = rf64
+
+				// The odd size is padded.
+				data := make([]byte, 3*h.BlockAlign())
+				for i := range data {
+					data[i] = byte(i)
+				}
+
+				var b seekBuffer
+				w,err := NewWriter(&b, h)
+				if err != nil {
+					t.Error("create writer failed, err is", err)
+					return
+				}
+				if n,err := w.Write(data); n != len(data) || err != nil {
+					t.Error("write failed", n, err)
+				}
+				if err = w.Close(); err != nil || w.RF64 != rf64 || b.size%2 != 0 {
+					t.Error("close failed", err, w.RF64, b.size)
+				}
+				if _,err = w.Write(data); !errors.Is(err, aresample.ErrClosed) {
+					t.Error("invalid write", err)
+				}
+
+				r,err := NewReader(bytes.NewReader(b.b))
+				if err != nil {
+					t.Error("parse failed, err is", err)
+					return
+				}
+				if r.Header != h || r.Frames() != 3 {
+					t.Error("invalid header", r.Header, h, r.Frames())
+				}
+				if pcm,err := io.ReadAll(r); err != nil || !bytes.Equal(pcm, data) {
+					t.Error("invalid data", err)
+				}
+			}
+		}
+	}
+}
+
+func TestWriterRF64(t *testing.T) {
+	h,_ := NewHeader(aresample.FormatS16LE, 2, 48000)
+	b := &seekBuffer{limit: 1024}
+	w,err := NewWriter(b, h)
+	if err != nil {
+		t.Error("create writer failed, err is", err)
+		return
+	}
+
+	// Write more than 4GB, which upgrades to RF64.
+	p := make([]byte, 1<<20)
+	for i:=0; i<4097; i++ {
+		if _,err = w.Write(p); err != nil {
+			t.Error("write failed, err is", err)
+			return
+		}
+	}
+	if err = w.Close(); err != nil || !w.RF64 {
+		t.Error("close failed", err, w.RF64)
+	}
+
+	r,err := NewReader(bytes.NewReader(b.b))
+	if err != nil {
+		t.Error("parse failed, err is", err)
+		return
+	}
+	if !r.RF64 || r.Size() != 4097<<20 || r.Frames() != 4097<<18 {
+		t.Error("invalid rf64", r.RF64, r.Size(), r.Frames())
+	}
+	if string(b.b[0:4]) != "RF64" || string(b.b[12:16]) != "ds64" {
+		t.Error("invalid header", string(b.b[:16]))
+	}
+}
+
+func TestResample(t *testing.T) {
+	h,_ := NewHeader(aresample.FormatS16LE, 2, 44100)
+	var b seekBuffer
+	w,_ := NewWriter(&b, h)
+	w.Write(make([]byte, 4*4410))
+	w.Close()
+
+	// Configure the resampler by the header.
+	r,err := NewReader(bytes.NewReader(b.b))
+	if err != nil {
+		t.Error("parse failed, err is", err)
+		return
+	}
+	cfg,err := r.Config(48000)
+	if err != nil {
+		t.Error("invalid config, err is", err)
+		return
+	}
+	pcm,err := io.ReadAll(r)
+	if err != nil {
+		t.Error("read failed, err is", err)
+		return
+	}
+	npcm,err := aresample.ResampleBuffer(pcm, cfg)
+	if err != nil || len(npcm) != 4*4800 {
+		t.Error("resample failed", len(npcm), err)
+	}
+}
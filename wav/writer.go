@@ -0,0 +1,140 @@
+// The MIT License (MIT)
+//
+// Copyright (c) 2016 winlin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+
+// The WAV file reader and writer.
+package wav
+
+import (
+	"encoding/binary"
+	"io"
+	"github.com/winlinvip/go-aresample/aresample"
+)
+
+// The size of ds64 chunk, without the table.
+const ds64Size = 28
+
+// The writer of WAV file, which writes the header, and patches the sizes when Close.
+// The header reserves a JUNK chunk for ds64, so the file is upgraded to RF64 when
+// more than 4GB, see EBU Tech 3306.
+type Writer struct {
+	Header
+	w       io.WriteSeeker
+	start   int64  // The offset of header.
+	written uint64 // The size of data written.
+	closed  bool
+}
+
+// Create the writer, which writes the header of h, for example, by NewHeader.
+func NewWriter(w io.WriteSeeker, h Header) (*Writer, error) {
+	if err := h.Validate(); err != nil {
+		return nil,err
+	}
+
+	start,err := w.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil,err
+	}
+
+	v := &Writer{Header: h, w: w, start: start}
+	b,_ := v.header(0)
+	if _,err = w.Write(b); err != nil {
+		return nil,err
+	}
+
+	return v,nil
+}
+
+// Write the samples to data chunk, in the format of header.
+func (v *Writer) Write(p []byte) (n int, err error) {
+	if v.closed {
+		return 0,aresample.ErrClosed
+	}
+
+	n,err = v.w.Write(p)
+	v.written += uint64(n)
+	return
+}
+
+// Close the writer, which pads the data chunk to even size, and patches the sizes in header,
+// but not close the underlayer writer.
+func (v *Writer) Close() (err error) {
+	if v.closed {
+		return
+	}
+	v.closed = true
+
+	if v.written%2 != 0 {
+		if _,err = v.w.Write([]byte{0}); err != nil {
+			return
+		}
+	}
+
+	b,rf64 := v.header(v.written)
+	if _,err = v.w.Seek(v.start, io.SeekStart); err != nil {
+		return
+	}
+	if _,err = v.w.Write(b); err != nil {
+		return
+	}
+	if _,err = v.w.Seek(0, io.SeekEnd); err != nil {
+		return
+	}
+
+	v.RF64 = rf64
+	return
+}
+
+// The header of file with the size of data, use RF64 if required.
+func (v *Writer) header(size uint64) (b []byte, rf64 bool) {
+	fmtSize := v.fmtSize()
+	b = make([]byte, 12 + 8+ds64Size + 8+fmtSize + 8)
+
+	riffSize := uint64(len(b)) - 8 + size + size%2
+	rf64 = v.RF64 || riffSize > riffMaxSize
+
+	copy(b[0:], "RIFF")
+	binary.LittleEndian.PutUint32(b[4:], uint32(riffSize))
+	copy(b[8:], "WAVE")
+	copy(b[12:], "JUNK")
+	binary.LittleEndian.PutUint32(b[16:], ds64Size)
+	if rf64 {
+		copy(b[0:], "RF64")
+		binary.LittleEndian.PutUint32(b[4:], riffMaxSize)
+		copy(b[12:], "ds64")
+		binary.LittleEndian.PutUint64(b[20:], riffSize)
+		binary.LittleEndian.PutUint64(b[28:], size)
+		binary.LittleEndian.PutUint64(b[36:], size / uint64(v.BlockAlign()))
+	}
+
+	off := 12 + 8+ds64Size
+	copy(b[off:], "fmt ")
+	binary.LittleEndian.PutUint32(b[off+4:], uint32(fmtSize))
+	v.marshal(b[off+8:])
+
+	off += 8+fmtSize
+	copy(b[off:], "data")
+	binary.LittleEndian.PutUint32(b[off+4:], uint32(size))
+	if rf64 {
+		binary.LittleEndian.PutUint32(b[off+4:], riffMaxSize)
+	}
+	return
+}